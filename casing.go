@@ -0,0 +1,78 @@
+package speller
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Saimunyz/speller/internal/spellcorrect"
+)
+
+// applyCase - applies capitalization pattern of original word to correction:
+// UPPER, Title or lower, mixed case words give lowercased correction
+func applyCase(original, correction string) string {
+	var upper, lower int
+	for _, r := range original {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	first, _ := utf8.DecodeRuneInString(original)
+	switch {
+	case upper == 0:
+		return correction
+	case lower == 0 && upper > 1:
+		return strings.ToUpper(correction)
+	case upper == 1 && unicode.IsUpper(first):
+		r, size := utf8.DecodeRuneInString(correction)
+		return string(unicode.ToTitle(r)) + correction[size:]
+	}
+	return correction
+}
+
+// casedTokens - returns corrections with capitalization of the original words
+func casedTokens(query string, spans []spellcorrect.Span, tokens []string) []string {
+	cased := make([]string, len(tokens))
+	for i := range tokens {
+		original := query[spans[i].Start:spans[i].End]
+		if strings.ToLower(original) == tokens[i] {
+			cased[i] = original
+			continue
+		}
+		if tokens[i] == "" {
+			continue
+		}
+		cased[i] = applyCase(original, tokens[i])
+	}
+	return cased
+}
+
+// project - replaces words of the query with their cased corrections,
+// the rest of the query stays byte-identical. Word with empty correction
+// is merged with the previous one, so text between them is dropped
+func project(query string, spans []spellcorrect.Span, cased []string) string {
+	var (
+		b    strings.Builder
+		prev int
+	)
+	b.Grow(len(query))
+	for i := range cased {
+		if i == 0 || !merged(query, spans[i], cased[i]) {
+			b.WriteString(query[prev:spans[i].Start])
+		}
+		b.WriteString(cased[i])
+		prev = spans[i].End
+	}
+	b.WriteString(query[prev:])
+	return b.String()
+}
+
+// merged - checks if the word of the query at span is merged with the
+// previous one, such words have empty correction
+func merged(query string, span spellcorrect.Span, correction string) bool {
+	return correction == "" && span.End > span.Start
+}
@@ -0,0 +1,151 @@
+// Command prune removes rare and uninformative n-grams from the speller model
+// and reports amount of n-grams, size of the model file and evaluation set
+// accuracy before and after pruning. Size after pruning is the size of the
+// written model, -flat and -quantize models are measured in their format.
+//
+//	prune -config config.yaml -model models/AllRu-model.gz -out models/pruned.gz \
+//		-eval eval.tsv -min-counts 1,2,2 -threshold 1e-8 -quantize 8
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Saimunyz/speller"
+)
+
+func main() {
+	var (
+		configPath = flag.String("config", "config.yaml", "speller config")
+		modelPath  = flag.String("model", "", "model to prune")
+		outPath    = flag.String("out", "", "path of pruned model, not saved if empty")
+		evalPath   = flag.String("eval", "", "evaluation set with \"query<TAB>expected\" lines")
+		minCounts  = flag.String("min-counts", "", "comma separated min counts of n-grams of each order")
+		threshold  = flag.Float64("threshold", 0, "relative entropy threshold, 0 disables it")
+		flat       = flag.Bool("flat", false, "save pruned model in flat format")
+		quantize   = flag.Int("quantize", 0, "bits of probs quantization of flat model: 8 or 16, implies -flat")
+	)
+	flag.Parse()
+
+	if *modelPath == "" {
+		log.Fatal("model is not set")
+	}
+	params := speller.PruneParams{Threshold: *threshold}
+	if *minCounts != "" {
+		for _, count := range strings.Split(*minCounts, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil {
+				log.Fatalf("wrong min counts %q: %v", *minCounts, err)
+			}
+			params.MinCounts = append(params.MinCounts, n)
+		}
+	}
+
+	var evalSet []byte
+	if *evalPath != "" {
+		var err error
+		evalSet, err = os.ReadFile(*evalPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	s, err := speller.New(speller.WithConfigFile(*configPath), speller.WithQuantization(*quantize))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := s.LoadModel(*modelPath); err != nil {
+		log.Fatal(err)
+	}
+	info, err := os.Stat(*modelPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	before := evaluate(s, evalSet, *evalPath)
+	stats, err := s.Prune(params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	after := evaluate(s, evalSet, *evalPath)
+
+	if *quantize != 0 {
+		*flat = true
+	}
+	size := save(s, *outPath, *flat)
+
+	fmt.Printf("%-8s %12s %12s\n", "order", "before", "after")
+	for i := range stats.Before {
+		fmt.Printf("%-8d %12d %12d\n", i+1, stats.Before[i], stats.After[i])
+	}
+	total, pruned := stats.Total()
+	fmt.Printf("%-8s %12d %12d\n", "total", total, pruned)
+	fmt.Printf("%-8s %12d %12d\n", "bytes", info.Size(), size)
+	if evalSet != nil {
+		fmt.Printf("%-8s %11.2f%% %11.2f%%\n", "accuracy", before.Accuracy()*100, after.Accuracy()*100)
+	}
+	if *quantize != 0 && evalSet != nil {
+		// probs are quantized only when the model is written
+		var buf bytes.Buffer
+		if err := s.SaveFlatModelTo(&buf); err != nil {
+			log.Fatal(err)
+		}
+		q, err := speller.New(speller.WithConfigFile(*configPath))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := q.LoadModelFrom(&buf); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("quantized to %d bits: accuracy %.2f%%\n", *quantize, evaluate(q, evalSet, *evalPath).Accuracy()*100)
+	}
+}
+
+// save - writes pruned model to path if it is set and returns size of the
+// written model, models are saved by rename, so models mapped from the path
+// stay intact
+func save(s *speller.Speller, path string, flat bool) int64 {
+	if path == "" {
+		var buf bytes.Buffer
+		write := s.SaveModelTo
+		if flat {
+			write = s.SaveFlatModelTo
+		}
+		if err := write(&buf); err != nil {
+			log.Fatal(err)
+		}
+		return int64(buf.Len())
+	}
+
+	var err error
+	if flat {
+		err = s.SaveFlatModel(path)
+	} else {
+		err = s.SaveModel(path)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return info.Size()
+}
+
+// evaluate - evaluates speller if evaluation set is given
+func evaluate(s *speller.Speller, evalSet []byte, path string) speller.Evaluation {
+	if evalSet == nil {
+		return speller.Evaluation{}
+	}
+	eval, err := s.Evaluate(bytes.NewReader(evalSet))
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return eval
+}
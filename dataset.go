@@ -0,0 +1,58 @@
+package speller
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+)
+
+var errNoDataset = errors.New("neither path nor reader is set")
+
+// dataset - dataset source, read errors are wrapped in DatasetError
+type dataset struct {
+	r       io.Reader
+	closers []io.Closer
+	path    string
+}
+
+// openDataset - opens given reader or gzipped dataset file if reader is nil
+func openDataset(r io.Reader, path string) (*dataset, error) {
+	if r != nil {
+		return &dataset{r: r}, nil
+	}
+	if path == "" {
+		return nil, &DatasetError{Err: errNoDataset}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, &DatasetError{Path: path, Err: err}
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, &DatasetError{Path: path, Err: err}
+	}
+
+	return &dataset{r: gz, closers: []io.Closer{gz, file}, path: path}, nil
+}
+
+func (o *dataset) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = &DatasetError{Path: o.path, Err: err}
+	}
+	return n, err
+}
+
+func (o *dataset) Close() error {
+	var err error
+	for _, c := range o.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package speller
+
+import (
+	"fmt"
+
+	"github.com/Saimunyz/speller/internal/spellcorrect"
+)
+
+// ConfigError - returned when the config can't be read or has invalid values
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("speller: invalid config: %v", e.Err)
+	}
+	return fmt.Sprintf("speller: invalid config %q: %v", e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// DatasetError - returned when a training dataset or the frequencies
+// dictionary is missing, is not a valid gzip stream or can't be parsed
+type DatasetError struct {
+	Path string
+	Err  error
+}
+
+func (e *DatasetError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("speller: dataset: %v", e.Err)
+	}
+	return fmt.Sprintf("speller: dataset %q: %v", e.Path, e.Err)
+}
+
+func (e *DatasetError) Unwrap() error {
+	return e.Err
+}
+
+// Errors wrapped in ModelError, check them with errors.Is
+var (
+	ErrModelFormat    = spellcorrect.ErrModelFormat
+	ErrModelVersion   = spellcorrect.ErrModelVersion
+	ErrModelTruncated = spellcorrect.ErrModelTruncated
+	ErrModelChecksum  = spellcorrect.ErrModelChecksum
+	ErrFlatSmoothing  = spellcorrect.ErrFlatSmoothing
+)
+
+// ModelError - returned when a model file is missing or corrupt,
+// Path is empty for models loaded from io.Reader
+type ModelError struct {
+	Path string
+	Err  error
+}
+
+func (e *ModelError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("speller: model: %v", e.Err)
+	}
+	return fmt.Sprintf("speller: model %q: %v", e.Path, e.Err)
+}
+
+func (e *ModelError) Unwrap() error {
+	return e.Err
+}
+
+// DictParseError - describes malformed line of the frequencies dictionary,
+// it is always wrapped in DatasetError
+type DictParseError = spellcorrect.DictParseError
+
+// PairParseError - describes malformed line of pairs of typos and their
+// corrections, it is always wrapped in DatasetError
+type PairParseError = spellcorrect.PairParseError
+
+// EvalParseError - describes malformed line of evaluation set
+type EvalParseError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *EvalParseError) Error() string {
+	return fmt.Sprintf("speller: evaluation set line %d %q: %v", e.Line, e.Text, e.Err)
+}
+
+func (e *EvalParseError) Unwrap() error {
+	return e.Err
+}
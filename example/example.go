@@ -11,18 +11,24 @@ import (
 func main() {
 	fmt.Println("Example of usage")
 	// create speller
-	speller := speller.NewSpeller("config.yaml")
+	speller, err := speller.NewSpellerFromConfig("config.yaml")
+	if err != nil {
+		panic(err)
+	}
 
 	// load model
-	err := speller.LoadModel("models/AllRu-model.gz")
+	err = speller.LoadModel("models/AllRu-model.gz")
 	if err != nil {
 		fmt.Printf("No such file: %v\n", err)
 		//panic(err)
 	}
 
 	// or train model and save
-	// speller.Train()
-	// err := speller.SaveModel("models/AllRu-model.gz")
+	// err = speller.Train()
+	// if err != nil {
+	// 	panic(err)
+	// }
+	// err = speller.SaveModel("models/AllRu-model.gz")
 	// if err != nil {
 	// 	fmt.Printf("No such file: %v\n", err)
 	// 	//panic(err)
@@ -4,12 +4,12 @@ go 1.17
 
 require (
 	github.com/eskriett/spell v0.0.0-20210919200434-03313e3b725f
+	github.com/eskriett/strmet v0.0.0-20200126103939-2653f802bdb0
 	github.com/segmentio/fasthash v1.0.3
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
 
 require (
-	github.com/eskriett/strmet v0.0.0-20200126103939-2653f802bdb0 // indirect
 	github.com/mitchellh/mapstructure v1.4.2 // indirect
 	github.com/tidwall/gjson v1.9.3 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
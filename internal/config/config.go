@@ -1,25 +1,75 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"path/filepath"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Learn queue policies
+const (
+	LearnDrop  = "drop"
+	LearnBlock = "block"
+)
+
+// Smoothing schemes of n-grams probs
+const (
+	SmoothingNone          = "none"
+	SmoothingStupidBackoff = "stupid_backoff"
+	SmoothingKatz          = "katz"
+	SmoothingKneserNey     = "kneser_ney"
+)
+
 // SpellerConfig - contains all parametrs for speller configuration
 type SpellerConfig struct {
 	SentencesPath string  `yaml:"sentences_path"`
 	DictPath      string  `yaml:"dict_path"`
+	PairsPath     string  `yaml:"pairs_path"`
 	MinWordFreq   int     `yaml:"min_word_freq"`
 	MinWordLength int     `yaml:"min_word_length"`
 	Penalty       float64 `yaml:"penalty"`
 	UnigramWeight float64 `yaml:"unigram_weight"`
 	BigramWeight  float64 `yaml:"bigram_weight"`
 	TrigramWeight float64 `yaml:"trigram_weight"`
+	NgramOrder    int     `yaml:"ngram_order"`
 	AutoTrainMode bool    `yaml:"auto_train_mode"`
+	JointDecoding bool    `yaml:"joint_decoding"`
+	// NgramWeights - weights of n-grams of each order starting from unigrams,
+	// unigram, bigram and trigram weights are used if unset and higher orders
+	// get the weight of trigrams
+	NgramWeights []float64 `yaml:"ngram_weights"`
+
+	MaxEditDistance    int `yaml:"max_edit_distance"`
+	LookupEditDistance int `yaml:"lookup_edit_distance"`
+	MaxCandidates      int `yaml:"max_candidates"`
+	MinLookupLength    int `yaml:"min_lookup_length"`
+	MaxSuggestions     int `yaml:"max_suggestions"`
+	WindowSize         int `yaml:"window_size"`
+	BeamWidth          int `yaml:"beam_width"`
+
+	LearnQueueSize   int    `yaml:"learn_queue_size"`
+	LearnQueuePolicy string `yaml:"learn_queue_policy"`
+
+	CorpusDescription string   `yaml:"corpus_description"`
+	QuantizationBits  int      `yaml:"quantization_bits"`
+	VerifyModel       bool     `yaml:"verify_model"`
+	Smoothing         string   `yaml:"smoothing"`
+	FunctionWords     []string `yaml:"function_words"`
+	AdjacentKeyCost   float64  `yaml:"adjacent_key_cost"`
+	// KeyboardLayouts - conversions of words typed with wrong keyboard layout,
+	// QWERTY and ЙЦУКЕН are converted if unset, empty list disables conversion
+	KeyboardLayouts []KeyboardLayout `yaml:"keyboard_layouts"`
+}
+
+// KeyboardLayout - keys of two keyboard layouts in the same order
+type KeyboardLayout struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
 }
 
 // Config - contains all configuration parameters in config package
@@ -31,12 +81,6 @@ func (o *Config) Validate() error {
 	// if o.SpellerConfig.Addr == "" {
 	// 	return fmt.Errorf("provide non empty SC_ADDR")
 	// }
-	if o.SpellerConfig.SentencesPath == "" {
-		return fmt.Errorf("you need to set 'sentences_path'")
-	}
-	if o.SpellerConfig.DictPath == "" {
-		return fmt.Errorf("you need to set 'dict_path'")
-	}
 	if o.SpellerConfig.MinWordLength == 0 {
 		return fmt.Errorf("you need to set non zero 'min_word_length'")
 	}
@@ -52,34 +96,108 @@ func (o *Config) Validate() error {
 	if o.SpellerConfig.TrigramWeight == 0 {
 		return fmt.Errorf("you need to set non zero 'trigram_weight'")
 	}
+	if o.SpellerConfig.NgramOrder < 1 || o.SpellerConfig.NgramOrder > 5 {
+		return fmt.Errorf("'ngram_order' must be from 1 to 5")
+	}
+	if weights := o.SpellerConfig.NgramWeights; len(weights) != 0 {
+		if len(weights) < o.SpellerConfig.NgramOrder {
+			return fmt.Errorf("'ngram_weights' must have weight of each order up to 'ngram_order'")
+		}
+		for _, weight := range weights {
+			if weight == 0 {
+				return fmt.Errorf("'ngram_weights' must be non zero")
+			}
+		}
+	}
+	if o.SpellerConfig.MinWordLength < 0 || o.SpellerConfig.MinWordFreq < 0 {
+		return fmt.Errorf("'min_word_length' and 'min_word_freq' can't be negative")
+	}
+	if o.SpellerConfig.Penalty < 0 {
+		return fmt.Errorf("'penalty' can't be negative")
+	}
+	if o.SpellerConfig.LookupEditDistance < 0 {
+		return fmt.Errorf("'lookup_edit_distance' can't be negative")
+	}
+	if o.SpellerConfig.LookupEditDistance > o.SpellerConfig.MaxEditDistance {
+		return fmt.Errorf("'lookup_edit_distance' can't be greater than 'max_edit_distance'")
+	}
+	if o.SpellerConfig.MaxCandidates < 1 {
+		return fmt.Errorf("you need to set positive 'max_candidates'")
+	}
+	if o.SpellerConfig.MaxSuggestions < 1 {
+		return fmt.Errorf("you need to set positive 'max_suggestions'")
+	}
+	if o.SpellerConfig.WindowSize < 1 {
+		return fmt.Errorf("you need to set positive 'window_size'")
+	}
+	if o.SpellerConfig.BeamWidth < 1 {
+		return fmt.Errorf("you need to set positive 'beam_width'")
+	}
+	if o.SpellerConfig.LearnQueueSize < 1 {
+		return fmt.Errorf("you need to set positive 'learn_queue_size'")
+	}
+	if o.SpellerConfig.LearnQueuePolicy != LearnDrop && o.SpellerConfig.LearnQueuePolicy != LearnBlock {
+		return fmt.Errorf("'learn_queue_policy' must be %q or %q", LearnDrop, LearnBlock)
+	}
+	if bits := o.SpellerConfig.QuantizationBits; bits != 0 && bits != 8 && bits != 16 {
+		return fmt.Errorf("'quantization_bits' must be 0, 8 or 16")
+	}
+	switch o.SpellerConfig.Smoothing {
+	case SmoothingNone, SmoothingStupidBackoff, SmoothingKatz, SmoothingKneserNey:
+	default:
+		return fmt.Errorf("'smoothing' must be %q, %q, %q or %q",
+			SmoothingNone, SmoothingStupidBackoff, SmoothingKatz, SmoothingKneserNey)
+	}
+	if o.SpellerConfig.QuantizationBits != 0 &&
+		(o.SpellerConfig.Smoothing == SmoothingKatz || o.SpellerConfig.Smoothing == SmoothingKneserNey) {
+		return fmt.Errorf("quantized flat models support only %q and %q 'smoothing'", SmoothingNone, SmoothingStupidBackoff)
+	}
+	if o.SpellerConfig.PairsPath != "" && o.SpellerConfig.Smoothing == SmoothingNone {
+		return fmt.Errorf("'pairs_path' needs 'smoothing' other than %q", SmoothingNone)
+	}
+	if o.SpellerConfig.AdjacentKeyCost <= 0 || o.SpellerConfig.AdjacentKeyCost > 1 {
+		return fmt.Errorf("'adjacent_key_cost' must be greater than 0 and not greater than 1")
+	}
+	for _, layout := range o.SpellerConfig.KeyboardLayouts {
+		if layout.From == "" || utf8.RuneCountInString(layout.From) != utf8.RuneCountInString(layout.To) {
+			return fmt.Errorf("'keyboard_layouts' must have the same non zero amount of keys 'from' and 'to'")
+		}
+	}
 	return nil
 }
 
 // ReadConfigYML - read configurations from file and init Config instance
-func ReadConfigYML(filePath string) (cfg *Config, err error) {
+func ReadConfigYML(filePath string) (*Config, error) {
+	cfg := &Config{}
+	if err := MergeConfigYML(filePath, cfg); err != nil {
+		return cfg, err
+	}
+	return SetDefault(cfg)
+}
+
+// MergeConfigYML - reads configurations from file over cfg, parameters
+// which are missing in the file keep their values. Defaults are not set
+func MergeConfigYML(filePath string, cfg *Config) (err error) {
 	file, err := os.Open(filepath.Clean(filePath))
 	if err != nil {
-		return cfg, err
+		return err
 	}
 	defer func() {
-		err := file.Close()
-		if err != nil {
-			log.Fatal(err)
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = cerr
 		}
 	}()
 
 	decoder := yaml.NewDecoder(file)
-	err = decoder.Decode(&cfg)
-	if err != nil {
-		return cfg, err
+	err = decoder.Decode(cfg)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file is empty")
 	}
-
-	setDefault(cfg)
-
-	return cfg, nil
+	return err
 }
 
-func setDefault(cfg *Config) (*Config, error) {
+// SetDefault - sets default values for all unset parameters and validates config
+func SetDefault(cfg *Config) (*Config, error) {
 	// if cfg.Addr == "" {
 	// 	cfg.Addr = ":10000"
 	// }
@@ -101,6 +219,42 @@ func setDefault(cfg *Config) (*Config, error) {
 	if cfg.SpellerConfig.TrigramWeight == 0 {
 		cfg.SpellerConfig.TrigramWeight = 80
 	}
+	if cfg.SpellerConfig.NgramOrder == 0 {
+		cfg.SpellerConfig.NgramOrder = 3
+	}
+	if cfg.SpellerConfig.MaxEditDistance == 0 {
+		cfg.SpellerConfig.MaxEditDistance = 3
+	}
+	if cfg.SpellerConfig.LookupEditDistance == 0 {
+		cfg.SpellerConfig.LookupEditDistance = 1
+	}
+	if cfg.SpellerConfig.MaxCandidates == 0 {
+		cfg.SpellerConfig.MaxCandidates = 5
+	}
+	if cfg.SpellerConfig.MinLookupLength == 0 {
+		cfg.SpellerConfig.MinLookupLength = 3
+	}
+	if cfg.SpellerConfig.MaxSuggestions == 0 {
+		cfg.SpellerConfig.MaxSuggestions = 10
+	}
+	if cfg.SpellerConfig.WindowSize == 0 {
+		cfg.SpellerConfig.WindowSize = cfg.SpellerConfig.NgramOrder
+	}
+	if cfg.SpellerConfig.BeamWidth == 0 {
+		cfg.SpellerConfig.BeamWidth = 10
+	}
+	if cfg.SpellerConfig.LearnQueueSize == 0 {
+		cfg.SpellerConfig.LearnQueueSize = 1024
+	}
+	if cfg.SpellerConfig.LearnQueuePolicy == "" {
+		cfg.SpellerConfig.LearnQueuePolicy = LearnDrop
+	}
+	if cfg.SpellerConfig.Smoothing == "" {
+		cfg.SpellerConfig.Smoothing = SmoothingNone
+	}
+	if cfg.SpellerConfig.AdjacentKeyCost == 0 {
+		cfg.SpellerConfig.AdjacentKeyCost = 0.5
+	}
 
 	return cfg, cfg.Validate()
 }
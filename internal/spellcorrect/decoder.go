@@ -0,0 +1,152 @@
+package spellcorrect
+
+import (
+	"sort"
+	"strings"
+)
+
+// latticeNode - partial suggestion of the lattice decoder: the last token,
+// index of the input token it corrects, its prefix and sum of log-probs of
+// all tokens
+type latticeNode struct {
+	prev  *latticeNode
+	token string
+	word  int
+	score float64
+	len   int
+}
+
+// context - writes up to n last tokens of the node into buf and returns them
+func (o *latticeNode) context(n int, buf []string) []string {
+	if n > o.len {
+		n = o.len
+	}
+	buf = buf[:n]
+	node := o
+	for i := n - 1; i >= 0; i-- {
+		buf[i] = node.token
+		node = node.prev
+	}
+	return buf
+}
+
+// tokens - returns all tokens of the node
+func (o *latticeNode) tokens() []string {
+	return o.context(o.len, make([]string, o.len))
+}
+
+// corrections - returns correction of each of n input tokens, tokens of
+// split input token are separated by space and input token merged with
+// the previous one gets empty correction
+func (o *latticeNode) corrections(n int) []string {
+	corrections := make([]string, n)
+	for node := o; node != nil; node = node.prev {
+		if corrections[node.word] == "" {
+			corrections[node.word] = node.token
+		} else {
+			corrections[node.word] = node.token + " " + corrections[node.word]
+		}
+	}
+	return corrections
+}
+
+// decode - returns n best suggestions built of candidates of each token and
+// merges of adjacent tokens, the rest of slice is filled with empty
+// suggestions if there are less of them. Lattice of candidates is decoded by
+// beam search keeping the best partial suggestions of each amount of input
+// tokens, each token is scored by its smoothed log-prob given previous
+// tokens, so time is linear in the amount of tokens. Without smoothing counts
+// are backed off as stupid backoff does. Split candidates are words
+// separated by space, score is mean per input token
+func (o *SpellCorrector) decode(allSuggestions [][]string, merges [][]candidate, dist []map[string]float64, n int) []Suggestion {
+	suggestions := newSuggestions(n)
+	if len(allSuggestions) == 0 {
+		return suggestions
+	}
+
+	order := o.frequencies.NgramOrder()
+	width := o.params.BeamWidth
+	if width < n {
+		width = n
+	}
+
+	// beams[i] - partial suggestions of the first i tokens
+	beams := make([][]*latticeNode, len(allSuggestions)+1)
+	beams[0] = []*latticeNode{nil}
+	buf := make([]string, order)
+	for i, candidates := range allSuggestions {
+		candidates = unique(candidates)
+		for _, prev := range prune(beams[i], width) {
+			for _, candidate := range candidates {
+				node := prev
+				for _, token := range strings.Split(candidate, " ") {
+					node = o.extend(node, token, i, order, buf)
+				}
+				beams[i+1] = append(beams[i+1], o.penalize(node, prev, dist[i][candidate]))
+			}
+			if i < len(merges) {
+				for _, m := range merges[i] {
+					node := o.extend(prev, m.word, i, order, buf)
+					beams[i+2] = append(beams[i+2], o.penalize(node, prev, m.cost))
+				}
+			}
+		}
+	}
+
+	beam := prune(beams[len(allSuggestions)], width)
+	for i := 0; i < len(beam) && i < n; i++ {
+		suggestions[i] = Suggestion{
+			score:       beam[i].score / float64(len(allSuggestions)),
+			Tokens:      beam[i].tokens(),
+			Corrections: beam[i].corrections(len(allSuggestions)),
+		}
+	}
+	return suggestions
+}
+
+// extend - returns node continuing prev by the token correcting input token
+// word, the token is scored by its log-prob given previous tokens
+func (o *SpellCorrector) extend(prev *latticeNode, token string, word int, order int, buf []string) *latticeNode {
+	node := &latticeNode{prev: prev, token: token, word: word, len: 1}
+	if prev != nil {
+		node.score = prev.score
+		node.len = prev.len + 1
+	}
+	node.score += o.frequencies.LogProb(node.context(order, buf))
+	return node
+}
+
+// penalize - subtracts penalty of the candidate with cost distance from
+// score of the node which extends prev by tokens of the candidate
+func (o *SpellCorrector) penalize(node, prev *latticeNode, distance float64) *latticeNode {
+	logProb := node.score
+	if prev != nil {
+		logProb -= prev.score
+	}
+	node.score -= o.candidatePenalty(logProb, distance)
+	return node
+}
+
+// prune - returns up to width best partial suggestions
+func prune(beam []*latticeNode, width int) []*latticeNode {
+	sort.SliceStable(beam, func(i, j int) bool {
+		return beam[i].score > beam[j].score
+	})
+	if len(beam) > width {
+		beam = beam[:width]
+	}
+	return beam
+}
+
+// unique - returns words without repeats keeping their order
+func unique(words []string) []string {
+	seen := make(map[string]struct{}, len(words))
+	out := make([]string, 0, len(words))
+	for _, word := range words {
+		if _, ok := seen[word]; !ok {
+			seen[word] = struct{}{}
+			out = append(out, word)
+		}
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package spellcorrect
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func trainDecoderCorrector(t *testing.T) *SpellCorrector {
+	sentences := strings.Repeat("yellow table cloth for the kitchen\nred table lamp for the bedroom\n", 3)
+	sc := NewSpellCorrector(NewSimpleTokenizer(), NewFrequencies(0, 0, DefaultNgramOrder), []float64{1, 5, 4}, false, 1, 1.5)
+	sc.SetSmoothing(StupidBackoff)
+	if err := sc.frequencies.TrainNgrams(strings.NewReader(sentences)); err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+func TestDecodeMatchesExhaustiveSearch(t *testing.T) {
+	sc := trainDecoderCorrector(t)
+	allSuggestions := [][]string{
+		{"yellow", "red", "bed"},
+		{"table", "cable", "tale"},
+		{"cloth", "lamp", "clot"},
+		{"for", "far"},
+	}
+	dist := []map[string]float64{
+		{"red": 1, "bed": 2},
+		{"cable": 1, "tale": 1},
+		{"lamp": 2, "clot": 1},
+		{"far": 1},
+	}
+
+	best := Suggestion{score: math.Inf(-1)}
+	for i, combination := range combos(allSuggestions) {
+		tokens := strings.Split(combination, " ")
+		if score := sc.score(tokens, combinationCosts(allSuggestions, dist, i)); score > best.score {
+			best = Suggestion{score: score, Tokens: tokens}
+		}
+	}
+
+	decoded := sc.decode(allSuggestions, nil, dist, 3)
+	if strings.Join(decoded[0].Tokens, " ") != strings.Join(best.Tokens, " ") {
+		t.Errorf("decoded %q, best is %q", decoded[0].Tokens, best.Tokens)
+	}
+	if math.Abs(decoded[0].score-best.score) > 1e-9 {
+		t.Errorf("decoded score %f, best is %f", decoded[0].score, best.score)
+	}
+	for i := 1; i < len(decoded); i++ {
+		if decoded[i].score > decoded[i-1].score {
+			t.Errorf("suggestions are not ordered by score")
+		}
+	}
+}
+
+func TestDecodeLongQuery(t *testing.T) {
+	sc := trainDecoderCorrector(t)
+
+	// 5^40 combinations can't be enumerated
+	var allSuggestions [][]string
+	for i := 0; i < 8; i++ {
+		for _, word := range strings.Fields("yellow table cloth for kitchen") {
+			allSuggestions = append(allSuggestions, []string{word + "x", word, "red", "lamp", "the"})
+		}
+	}
+
+	suggestions := sc.decode(allSuggestions, nil, make([]map[string]float64, len(allSuggestions)), 2)
+	if len(suggestions[0].Tokens) != len(allSuggestions) {
+		t.Fatalf("decoded %d tokens, expected %d", len(suggestions[0].Tokens), len(allSuggestions))
+	}
+	if suggestions[0].Tokens[1] != "table" || suggestions[0].Tokens[2] != "cloth" {
+		t.Errorf("wrong decoded tokens %q", suggestions[0].Tokens[:5])
+	}
+	if suggestions[1].Tokens == nil {
+		t.Errorf("second suggestion is missing")
+	}
+}
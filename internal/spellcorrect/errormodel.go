@@ -0,0 +1,276 @@
+package spellcorrect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// errorModelMaxEdits - pairs which differ by more edits are not typos
+// but different queries, they are skipped in training
+const errorModelMaxEdits = 3
+
+// wordStart - context of edits at the beginning of the text
+const wordStart = "\x00"
+
+var errMissingPair = errors.New("expected \"typed<TAB>correction\"")
+
+// PairParseError - describes malformed line of typos and corrections pairs
+type PairParseError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *PairParseError) Error() string {
+	return fmt.Sprintf("pairs line %d %q: %v", e.Line, e.Text, e.Err)
+}
+
+func (e *PairParseError) Unwrap() error {
+	return e.Err
+}
+
+// errorModel - noisy channel model of typos, probabilities of character
+// edits are learned from pairs of typed texts and their corrections as
+// counts of edits relative to counts of their contexts in corrections:
+// substitution of x by y and insertion of y after x given x, deletion of
+// y after x and transposition of xy given bigram xy. Space is a character
+// too, so missing and extra spaces are learned
+type errorModel struct {
+	Pairs    int               // amount of trained pairs
+	Kept     uint64            // characters typed without edits
+	Edited   uint64            // amount of edits
+	Contexts map[string]uint64 // characters and bigrams of corrections
+	Edits    map[string]uint64 // counts of edits by their keys
+}
+
+// edit - edit of the correction which gives typed text: substitution,
+// deletion, insertion or transposition of runes x and y
+type edit struct {
+	kind byte
+	x, y rune
+}
+
+// Kinds of edits
+const (
+	editSubstitution  = 's'
+	editDeletion      = 'd'
+	editInsertion     = 'i'
+	editTransposition = 't'
+)
+
+// key - returns key of the edit in counts of edits
+func (o edit) key() string {
+	return string(o.kind) + contextKey(o.x) + string(o.y)
+}
+
+// context - returns key of the edit context in counts of contexts
+func (o edit) context() string {
+	switch o.kind {
+	case editDeletion, editTransposition:
+		return contextKey(o.x) + string(o.y)
+	}
+	return contextKey(o.x)
+}
+
+// contextKey - returns character as context, zero rune is the beginning of text
+func contextKey(r rune) string {
+	if r == 0 {
+		return wordStart
+	}
+	return string(r)
+}
+
+func newErrorModel() *errorModel {
+	return &errorModel{
+		Contexts: make(map[string]uint64),
+		Edits:    make(map[string]uint64),
+	}
+}
+
+// train - learns edits of "typed<TAB>correction[<TAB>count]" lines,
+// texts are normalized as queries are
+func (o *errorModel) train(in io.Reader) error {
+	var line int
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		parts := strings.Split(text, "\t")
+		if len(parts) < 2 {
+			return &PairParseError{Line: line, Text: text, Err: errMissingPair}
+		}
+		count := uint64(1)
+		if len(parts) > 2 {
+			var err error
+			count, err = strconv.ParseUint(strings.TrimSpace(parts[2]), 10, 64)
+			if err != nil {
+				return &PairParseError{Line: line, Text: text, Err: err}
+			}
+		}
+
+		o.add(normalizeText(parts[0]), normalizeText(parts[1]), count)
+	}
+
+	return scanner.Err()
+}
+
+// add - learns edits of correction which give typed text count times
+func (o *errorModel) add(typed, correction string, count uint64) {
+	edits, kept := alignEdits([]rune(typed), []rune(correction))
+	if len(edits) > errorModelMaxEdits || correction == "" {
+		return
+	}
+
+	o.Pairs++
+	o.Kept += uint64(kept) * count
+	o.Edited += uint64(len(edits)) * count
+	for _, e := range edits {
+		o.Edits[e.key()] += count
+	}
+
+	prev := wordStart
+	o.Contexts[prev] += count
+	for _, r := range correction {
+		o.Contexts[string(r)] += count
+		o.Contexts[prev+string(r)] += count
+		prev = string(r)
+	}
+}
+
+// logProb - returns log-prob of typing the correction as typed text:
+// characters are kept with probability learned from all pairs and edits
+// with probabilities given their contexts smoothed towards the mean
+// probability of edit, so edits of unseen contexts are unlikely
+func (o *errorModel) logProb(typed, correction string) float64 {
+	edits, kept := alignEdits([]rune(typed), []rune(correction))
+
+	total := float64(o.Kept + o.Edited + 2)
+	logProb := float64(kept) * math.Log(float64(o.Kept+1)/total)
+	alphabet := float64(len(o.Contexts) + 1)
+	prior := float64(o.Edited+1) / total / alphabet
+	for _, e := range edits {
+		logProb += math.Log((float64(o.Edits[e.key()]) + alphabet*prior) / (float64(o.Contexts[e.context()]) + alphabet))
+	}
+	return logProb
+}
+
+// alignEdits - returns edits of optimal string alignment of correction
+// and typed text and amount of kept runes
+func alignEdits(typed, correction []rune) ([]edit, int) {
+	// d[i][j] - distance between i runes of correction and j runes of typed
+	d := make([][]int, len(correction)+1)
+	for i := range d {
+		d[i] = make([]int, len(typed)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(correction); i++ {
+		for j := 1; j <= len(typed); j++ {
+			cost := 1
+			if correction[i-1] == typed[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j-1]+cost, minInt(d[i-1][j]+1, d[i][j-1]+1))
+			if i > 1 && j > 1 && correction[i-1] == typed[j-2] && correction[i-2] == typed[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	var (
+		edits []edit
+		kept  int
+	)
+	// previous rune of correction, zero rune at the beginning
+	prev := func(i int) rune {
+		if i == 0 {
+			return 0
+		}
+		return correction[i-1]
+	}
+	for i, j := len(correction), len(typed); i > 0 || j > 0; {
+		switch {
+		case i > 0 && j > 0 && correction[i-1] == typed[j-1] && d[i][j] == d[i-1][j-1]:
+			kept++
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && d[i][j] == d[i-1][j-1]+1:
+			edits = append(edits, edit{kind: editSubstitution, x: correction[i-1], y: typed[j-1]})
+			i, j = i-1, j-1
+		case i > 1 && j > 1 && correction[i-1] == typed[j-2] && correction[i-2] == typed[j-1] && d[i][j] == d[i-2][j-2]+1:
+			edits = append(edits, edit{kind: editTransposition, x: correction[i-2], y: correction[i-1]})
+			i, j = i-2, j-2
+		case i > 0 && d[i][j] == d[i-1][j]+1:
+			edits = append(edits, edit{kind: editDeletion, x: prev(i - 1), y: correction[i-1]})
+			i--
+		default:
+			edits = append(edits, edit{kind: editInsertion, x: prev(i), y: typed[j-1]})
+			j--
+		}
+	}
+	return edits, kept
+}
+
+// normalizeText - lowercases text, trims leading and trailing punctuation
+// of its words and separates them by single spaces
+func normalizeText(text string) string {
+	words := strings.Fields(text)
+	for i := range words {
+		words[i] = Normalize(words[i])
+	}
+	return strings.Join(words, " ")
+}
+
+// TrainErrorModel - trains noisy channel error model on lines of typed
+// texts and their corrections separated by tab with optional count of the
+// pair, e.g. logged queries and clicked ones. With smoothing candidates
+// are scored by n-grams log-prob plus log-prob of typing the token given
+// the candidate instead of edit distance penalties. Error model is saved
+// in the model, it must not be trained concurrently with corrections
+func (o *SpellCorrector) TrainErrorModel(in io.Reader) error {
+	model := newErrorModel()
+	if err := model.train(in); err != nil {
+		return err
+	}
+
+	o.learnMu.Lock()
+	o.channel = model
+	o.info.ErrorPairs = model.Pairs
+	o.learnMu.Unlock()
+	return nil
+}
+
+// noisyChannel - reports whether candidates are scored by error model
+func (o *SpellCorrector) noisyChannel() bool {
+	return o.channel != nil && o.channel.Pairs != 0 && o.smoothing != SmoothingNone
+}
+
+// cost - returns penalty distance of the candidate word of the token:
+// negative log-prob of typing the token given the word with noisy channel,
+// otherwise given distance
+func (o *SpellCorrector) cost(token, word string, distance float64) float64 {
+	if !o.noisyChannel() {
+		return distance
+	}
+	return -o.channel.logProb(token, word)
+}
+
+// candidatePenalty - returns penalty of log-prob of the candidate with cost
+// distance: the cost itself with noisy channel, so score is log-prob of the
+// candidate and the typo, otherwise percentage of log-prob growing with
+// edit distance
+func (o *SpellCorrector) candidatePenalty(logProb, distance float64) float64 {
+	if o.noisyChannel() {
+		return distance
+	}
+	return getPenalty(logProb, distance)
+}
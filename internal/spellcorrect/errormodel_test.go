@@ -0,0 +1,102 @@
+package spellcorrect
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAlignEdits(t *testing.T) {
+	for _, test := range []struct {
+		typed, correction string
+		edits             []edit
+		kept              int
+	}{
+		{"сыр", "сыр", nil, 3},
+		{"сир", "сыр", []edit{{editSubstitution, 'ы', 'и'}}, 2},
+		{"сры", "сыр", []edit{{editTransposition, 'ы', 'р'}}, 1},
+		{"ср", "сыр", []edit{{editDeletion, 'с', 'ы'}}, 2},
+		{"ыр", "сыр", []edit{{editDeletion, 0, 'с'}}, 2},
+		{"сыыр", "сыр", []edit{{editInsertion, 'с', 'ы'}}, 3},
+		{"всыр", "сыр", []edit{{editInsertion, 0, 'в'}}, 3},
+		{"всыр", "в сыр", []edit{{editDeletion, 'в', ' '}}, 4},
+	} {
+		edits, kept := alignEdits([]rune(test.typed), []rune(test.correction))
+		if !reflect.DeepEqual(edits, test.edits) || kept != test.kept {
+			t.Errorf("edits of %q typed for %q are %v and %d kept, expected %v and %d",
+				test.typed, test.correction, edits, kept, test.edits, test.kept)
+		}
+	}
+}
+
+func TestErrorModelTrain(t *testing.T) {
+	model := newErrorModel()
+	pairs := "сир\tсыр\t10\nСир!\tсыр\nсыр\tсыр\t5\nкупить\tпродать\n\nмилый кот\tмилый кот\n"
+	if err := model.train(strings.NewReader(pairs)); err != nil {
+		t.Fatal(err)
+	}
+	// pair of different queries is skipped
+	if model.Pairs != 4 {
+		t.Errorf("trained %d pairs, expected 4", model.Pairs)
+	}
+	if learned, unseen := model.logProb("сир", "сыр"), model.logProb("сар", "сыр"); learned <= unseen {
+		t.Errorf("learned typo %f is not more probable than unseen %f", learned, unseen)
+	}
+	if correct, typo := model.logProb("сыр", "сыр"), model.logProb("сир", "сыр"); correct <= typo {
+		t.Errorf("correct text %f is not more probable than typo %f", correct, typo)
+	}
+
+	var parseErr *PairParseError
+	if err := newErrorModel().train(strings.NewReader("сир\tсыр\nсир сыр\n")); !errors.As(err, &parseErr) || parseErr.Line != 2 {
+		t.Errorf("expected parse error of line 2, got %v", err)
+	}
+	if err := newErrorModel().train(strings.NewReader("сир\tсыр\tmany\n")); !errors.As(err, &parseErr) {
+		t.Errorf("expected parse error of count, got %v", err)
+	}
+}
+
+func TestNoisyChannel(t *testing.T) {
+	sentences := strings.Repeat("купить сыр\n", 2) + strings.Repeat("убрать сор\n", 5)
+	sc := NewSpellCorrector(NewSimpleTokenizer(), NewFrequencies(0, 0, DefaultNgramOrder), []float64{1, 5, 4}, false, 1, 1.5)
+	sc.SetSmoothing(StupidBackoff)
+	if err := sc.Train(strings.NewReader(sentences), strings.NewReader("купить 10\nсыр 10\nубрать 10\nсор 50\n")); err != nil {
+		t.Fatal(err)
+	}
+	if correct := sc.SpellCorrect("сир")[0].Tokens[0]; correct != "сор" {
+		t.Fatalf("wrong correction without error model %q", correct)
+	}
+
+	if err := sc.TrainErrorModel(strings.NewReader("сир\tсыр\t100\nмир\tмыр\t100\nсыр\tсыр\t1000\n")); err != nil {
+		t.Fatal(err)
+	}
+	if correct := sc.SpellCorrect("сир")[0].Tokens[0]; correct != "сыр" {
+		t.Errorf("wrong correction with error model %q", correct)
+	}
+	if info := sc.ModelInfo(); info.ErrorPairs != 3 {
+		t.Errorf("model info has %d error pairs, expected 3", info.ErrorPairs)
+	}
+
+	var buf bytes.Buffer
+	if err := sc.SaveModelTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewSpellCorrector(NewSimpleTokenizer(), NewFrequencies(0, 0, DefaultNgramOrder), []float64{1, 5, 4}, false, 1, 1.5)
+	loaded.SetSmoothing(StupidBackoff)
+	if err := loaded.LoadModelFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if correct := loaded.SpellCorrect("сир")[0].Tokens[0]; correct != "сыр" {
+		t.Errorf("wrong correction with loaded error model %q", correct)
+	}
+	if info := loaded.ModelInfo(); info.ErrorPairs != 3 {
+		t.Errorf("loaded model info has %d error pairs, expected 3", info.ErrorPairs)
+	}
+
+	// error model is not used without smoothing
+	loaded.SetSmoothing(SmoothingNone)
+	if loaded.noisyChannel() {
+		t.Errorf("noisy channel is used without smoothing")
+	}
+}
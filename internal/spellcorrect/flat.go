@@ -0,0 +1,563 @@
+package spellcorrect
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"unsafe"
+)
+
+// Flat n-grams model layout, all numbers are little-endian:
+//
+//	magic "SPELLNGM", format version uint32, n-grams order uint32,
+//	min word length uint32, min word freq uint32, total words uint64,
+//	quantization bits uint32, 4 zero bytes,
+//	amount of n-grams of each order uint64,
+//	then for each order: hashes []uint64, probs and counts []uint64 or,
+//	for quantized model, codebook size uint64, codebook of log-probs
+//	[]float64 and codes []uint8 or []uint16 padded to 8 bytes, and, except
+//	the last order, offsets []uint64 of len amount+1.
+//
+// Version 1 has no quantization bits and is never quantized.
+//
+// N-grams of each order are sorted by index of their prefix in the previous
+// order and then by hash of the last word, so continuations of n-gram i are
+// n-grams offsets[i]:offsets[i+1] of the next order. Probs of the first order
+// are unigram probs, probs of others are conditional on the prefix, the same
+// as in Frequencies. All sections are 8 bytes aligned, so the file can be
+// memory mapped and used without decoding.
+const (
+	flatMagic   = "SPELLNGM"
+	flatVersion = 2
+)
+
+var (
+	// ErrReadOnly - returned by training methods of FlatFrequencies
+	ErrReadOnly = errors.New("flat n-grams model is read-only")
+	// ErrFlatSmoothing - flat model is loaded with smoothing which needs counts
+	// it doesn't have
+	ErrFlatSmoothing = errors.New("flat n-grams model supports only none and stupid backoff smoothing")
+)
+
+// flatLevel - n-grams of one order, probs and counts are nil if the
+// model is quantized
+type flatLevel struct {
+	hashes  []uint64
+	probs   []float64
+	counts  []uint64
+	offsets []uint64
+	codes   codes
+}
+
+// prob - returns prob of n-gram j
+func (o *flatLevel) prob(j int) float64 {
+	if o.probs != nil {
+		return o.probs[j]
+	}
+	return o.codes.prob(j)
+}
+
+// FlatFrequencies - read-only n-grams model in flat binary format, safe for
+// concurrent use. Model opened with OpenFlatFrequencies is memory mapped,
+// it is shared between processes and unmapped when FlatFrequencies is no
+// longer referenced. Mapped file must be replaced by rename as SaveModel does
+// and never rewritten in place: truncated file crashes readers with SIGBUS
+// and changed one silently changes probs. TrainNgrams and TrainNgramsOnline return ErrReadOnly,
+// so only spell dictionary is learned in auto train mode
+type FlatFrequencies struct {
+	mu sync.RWMutex
+	*flatModel
+}
+
+// flatModel - parsed content of flat model
+type flatModel struct {
+	data    []byte
+	mapping *mapping
+	minWord int
+	minFreq int
+	total   uint64
+	bits    int
+	levels  []flatLevel
+}
+
+// mapping - memory mapped file, unmapped by finalizer
+type mapping struct {
+	data []byte
+}
+
+// OpenFlatFrequencies - memory maps flat n-grams model file
+func OpenFlatFrequencies(filename string) (*FlatFrequencies, error) {
+	model, err := openFlatModel(filename, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &FlatFrequencies{flatModel: model}, nil
+}
+
+// ReadFlatFrequencies - reads flat n-grams model from r into memory
+func ReadFlatFrequencies(r io.Reader) (*FlatFrequencies, error) {
+	model, err := readFlatModel(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &FlatFrequencies{flatModel: model}, nil
+}
+
+// openFlatModel - memory maps file and parses flat model starting at offset,
+// trailer strips data which follows the model if it is not nil
+func openFlatModel(filename string, offset int64, trailer func([]byte) ([]byte, error)) (*flatModel, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := mmapFile(f)
+	if err != nil {
+		return nil, err
+	}
+	if offset > int64(len(m.data)) {
+		return nil, fmt.Errorf("%w: flat n-grams model offset %d", ErrModelTruncated, offset)
+	}
+	data := m.data[offset:]
+	if trailer != nil {
+		if data, err = trailer(data); err != nil {
+			return nil, err
+		}
+	}
+	model, err := parseFlatModel(data)
+	if err != nil {
+		return nil, err
+	}
+	model.mapping = m
+	return model, nil
+}
+
+// readFlatModel - reads flat model from r into memory, trailer strips data
+// which follows the model if it is not nil
+func readFlatModel(r io.Reader, trailer func([]byte) ([]byte, error)) (*flatModel, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if trailer != nil {
+		if data, err = trailer(data); err != nil {
+			return nil, err
+		}
+	}
+	return parseFlatModel(data)
+}
+
+// parseFlatModel - checks flat model layout and slices data into levels
+// without copying on little-endian platforms
+func parseFlatModel(data []byte) (*flatModel, error) {
+	if len(data) < len(flatMagic) || string(data[:len(flatMagic)]) != flatMagic {
+		return nil, fmt.Errorf("%w: no flat n-grams model header", ErrModelFormat)
+	}
+	if len(data) < 32 {
+		return nil, ErrModelTruncated
+	}
+	le := binary.LittleEndian
+	headerSize := 40
+	switch v := le.Uint32(data[8:]); v {
+	case 1:
+		headerSize = 32
+	case flatVersion:
+	default:
+		return nil, fmt.Errorf("%w %d of flat n-grams model, supported 1 and %d", ErrModelVersion, v, flatVersion)
+	}
+	if len(data) < headerSize {
+		return nil, ErrModelTruncated
+	}
+	order := int(le.Uint32(data[12:]))
+	if order < 1 || order > 16 {
+		return nil, fmt.Errorf("%w: flat n-grams model order %d", ErrModelFormat, order)
+	}
+	model := &flatModel{
+		data:    data,
+		minWord: int(le.Uint32(data[16:])),
+		minFreq: int(le.Uint32(data[20:])),
+		total:   le.Uint64(data[24:]),
+		levels:  make([]flatLevel, order),
+	}
+	if headerSize > 32 {
+		model.bits = int(le.Uint32(data[32:]))
+	}
+	if model.bits != 0 && model.bits != 8 && model.bits != 16 {
+		return nil, fmt.Errorf("%w: flat n-grams model quantization bits %d", ErrModelFormat, model.bits)
+	}
+
+	pos := headerSize + 8*order
+	if len(data) < pos {
+		return nil, ErrModelTruncated
+	}
+	bytesSection := func(n uint64) ([]byte, error) {
+		// sections are padded to 8 bytes
+		size := (n + 7) &^ 7
+		if n > uint64(len(data)) || size > uint64(len(data)-pos) {
+			return nil, ErrModelTruncated
+		}
+		s := data[pos : pos+int(n)]
+		pos += int(size)
+		return s, nil
+	}
+	section := func(n uint64) ([]uint64, error) {
+		if n > uint64(len(data)-pos)/8 {
+			return nil, ErrModelTruncated
+		}
+		s := uint64s(data[pos : pos+int(n)*8])
+		pos += int(n) * 8
+		return s, nil
+	}
+
+	var err error
+	for i := range model.levels {
+		n := le.Uint64(data[headerSize+8*i:])
+		level := &model.levels[i]
+		if level.hashes, err = section(n); err != nil {
+			return nil, err
+		}
+		if model.bits == 0 {
+			probs, err := section(n)
+			if err != nil {
+				return nil, err
+			}
+			level.probs = float64s(probs)
+			if level.counts, err = section(n); err != nil {
+				return nil, err
+			}
+		} else {
+			size, err := section(1)
+			if err != nil {
+				return nil, err
+			}
+			if size[0] > 1<<model.bits {
+				return nil, fmt.Errorf("%w: flat n-grams model codebook size %d", ErrModelFormat, size[0])
+			}
+			codebook, err := section(size[0])
+			if err != nil {
+				return nil, err
+			}
+			raw, err := bytesSection(n * uint64(model.bits/8))
+			if err != nil {
+				return nil, err
+			}
+			level.codes = newCodes(model.bits, float64s(codebook), raw)
+		}
+		if i == order-1 {
+			continue
+		}
+		if level.offsets, err = section(n + 1); err != nil {
+			return nil, err
+		}
+		next := le.Uint64(data[headerSize+8*(i+1):])
+		if n > 0 && level.offsets[n] != next {
+			return nil, fmt.Errorf("%w: broken offsets of flat n-grams model", ErrModelFormat)
+		}
+	}
+	if pos != len(data) {
+		return nil, fmt.Errorf("%w: unexpected data after flat n-grams model", ErrModelFormat)
+	}
+
+	return model, nil
+}
+
+// Get - returns prob of n-gram, 0 if it is not in the model
+func (o *FlatFrequencies) Get(tokens []string) float64 {
+	o.mu.RLock()
+	model := o.flatModel
+	o.mu.RUnlock()
+
+	prob := model.get(tokens)
+	// mapping must not be unmapped until the search is finished
+	runtime.KeepAlive(model)
+	return prob
+}
+
+func (o *flatModel) get(tokens []string) float64 {
+	if len(tokens) == 0 || len(tokens) > len(o.levels) {
+		return 0.0
+	}
+
+	lo, hi := 0, len(o.levels[0].hashes)
+	for i, token := range tokens {
+		level := &o.levels[i]
+		hash := hashString(token)
+		j := lo + sort.Search(hi-lo, func(k int) bool {
+			return level.hashes[lo+k] >= hash
+		})
+		if j == hi || level.hashes[j] != hash {
+			return 0.0
+		}
+		if i == len(tokens)-1 {
+			return level.prob(j)
+		}
+		lo, hi = int(level.offsets[j]), int(level.offsets[j+1])
+		if lo > hi || hi > len(o.levels[i+1].hashes) {
+			return 0.0
+		}
+	}
+	return 0.0
+}
+
+// TrainParams - returns min word length and min word freq the model was trained with
+func (o *FlatFrequencies) TrainParams() (minWord, minFreq int) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.minWord, o.minFreq
+}
+
+// QuantizationBits - returns bits of quantized probs, 0 if probs are not quantized
+func (o *FlatFrequencies) QuantizationBits() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.bits
+}
+
+// NgramOrder - returns max order of n-grams in the model
+func (o *FlatFrequencies) NgramOrder() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return len(o.levels)
+}
+
+// TrainNgrams - returns ErrReadOnly
+func (o *FlatFrequencies) TrainNgrams(in io.Reader) error {
+	return ErrReadOnly
+}
+
+// TrainNgramsOnline - returns ErrReadOnly
+func (o *FlatFrequencies) TrainNgramsOnline(tokens []string) error {
+	return ErrReadOnly
+}
+
+// SaveModel - saves model to file in flat format, existing file is
+// atomically replaced
+func (o *FlatFrequencies) SaveModel(filename string) error {
+	return saveFile(filename, o.SaveModelTo)
+}
+
+// SaveModelTo - writes model to w in flat format
+func (o *FlatFrequencies) SaveModelTo(w io.Writer) error {
+	return o.EncodeModel(w)
+}
+
+// EncodeModel - writes model to w in flat format, the same as SaveModelTo
+func (o *FlatFrequencies) EncodeModel(w io.Writer) error {
+	o.mu.RLock()
+	model := o.flatModel
+	o.mu.RUnlock()
+
+	_, err := w.Write(model.data)
+	runtime.KeepAlive(model)
+	return err
+}
+
+// WriteFlat - writes model to w in flat format, the same as EncodeModel,
+// probs stay as they are, bits are ignored
+func (o *FlatFrequencies) WriteFlat(w io.Writer, bits int) error {
+	return o.EncodeModel(w)
+}
+
+// LoadModel - memory maps flat n-grams model file in place of current model
+func (o *FlatFrequencies) LoadModel(filename string) error {
+	model, err := openFlatModel(filename, 0, nil)
+	if err != nil {
+		return err
+	}
+	o.set(model)
+	return nil
+}
+
+// LoadModelFS - reads flat n-grams model from file of fsys into memory
+func (o *FlatFrequencies) LoadModelFS(fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return o.LoadModelFrom(f)
+}
+
+// LoadModelFrom - reads flat n-grams model from r into memory
+func (o *FlatFrequencies) LoadModelFrom(r io.Reader) error {
+	return o.DecodeModel(r)
+}
+
+// DecodeModel - reads flat n-grams model from r into memory, the same as LoadModelFrom
+func (o *FlatFrequencies) DecodeModel(r io.Reader) error {
+	model, err := readFlatModel(r, nil)
+	if err != nil {
+		return err
+	}
+	o.set(model)
+	return nil
+}
+
+func (o *FlatFrequencies) set(model *flatModel) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.flatModel = model
+}
+
+// WriteFlat - writes model to w in flat format which can be opened
+// with OpenFlatFrequencies, probs are quantized to 8 or 16 bits if bits
+// is not 0 and counts are not written then
+func (o *Frequencies) WriteFlat(w io.Writer, bits int) error {
+	if bits != 0 && bits != 8 && bits != 16 {
+		return fmt.Errorf("quantization bits must be 0, 8 or 16, got %d", bits)
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	trie := o.Trie
+	nodes := trie.wire()
+
+	// children of each node are sorted by hash and start at first[parent]
+	sorted := make([]uint32, trie.size())
+	first := make([]int, len(trie.freqs)+1)
+	for i := range sorted {
+		sorted[i] = uint32(i + 1)
+		first[nodes.Parents[i]+1]++
+	}
+	for i := 1; i < len(first); i++ {
+		first[i] += first[i-1]
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		pa, pb := nodes.Parents[sorted[a]-1], nodes.Parents[sorted[b]-1]
+		if pa != pb {
+			return pa < pb
+		}
+		return nodes.Hashes[sorted[a]-1] < nodes.Hashes[sorted[b]-1]
+	})
+
+	// nodes of each order in file order
+	levels := make([][]uint32, o.Order)
+	offsets := make([][]uint64, o.Order)
+
+	parents := []uint32{0}
+	for i := 0; i < o.Order; i++ {
+		offsets[i] = make([]uint64, 0, len(parents)+1)
+		for _, parent := range parents {
+			offsets[i] = append(offsets[i], uint64(len(levels[i])))
+			levels[i] = append(levels[i], sorted[first[parent]:first[parent+1]]...)
+		}
+		offsets[i] = append(offsets[i], uint64(len(levels[i])))
+		parents = levels[i]
+	}
+
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+	var buf [8]byte
+	put32 := func(v uint32) {
+		le.PutUint32(buf[:4], v)
+		bw.Write(buf[:4])
+	}
+	put64 := func(v uint64) {
+		le.PutUint64(buf[:], v)
+		bw.Write(buf[:])
+	}
+
+	bw.WriteString(flatMagic)
+	put32(flatVersion)
+	put32(uint32(o.Order))
+	put32(uint32(o.MinWord))
+	put32(uint32(o.MinFreq))
+	put64(uint64(trie.rootFreq))
+	put32(uint32(bits))
+	put32(0)
+	for i := range levels {
+		put64(uint64(len(levels[i])))
+	}
+
+	for i := range levels {
+		for _, node := range levels[i] {
+			put64(nodes.Hashes[node-1])
+		}
+		probs := make([]float64, len(levels[i]))
+		for j, node := range levels[i] {
+			probs[j] = float64(trie.freq(node)) / float64(trie.freq(nodes.Parents[node-1]))
+		}
+
+		if bits == 0 {
+			for _, prob := range probs {
+				put64(math.Float64bits(prob))
+			}
+			for _, node := range levels[i] {
+				put64(uint64(trie.freq(node)))
+			}
+		} else {
+			codebook, codes, err := quantize(probs, bits)
+			if err != nil {
+				return err
+			}
+			put64(uint64(len(codebook)))
+			for _, value := range codebook {
+				put64(math.Float64bits(value))
+			}
+			for _, code := range codes {
+				if bits == 8 {
+					bw.WriteByte(byte(code))
+				} else {
+					le.PutUint16(buf[:2], code)
+					bw.Write(buf[:2])
+				}
+			}
+			// codes are padded to 8 bytes
+			for size := len(codes) * bits / 8; size%8 != 0; size++ {
+				bw.WriteByte(0)
+			}
+		}
+		// offsets of the next order point to continuations of this one
+		if i+1 < len(levels) {
+			for _, offset := range offsets[i+1] {
+				put64(offset)
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// littleEndian - true if the platform stores numbers in flat model byte order
+var littleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// uint64s - returns data as []uint64, data must be 8 bytes aligned
+func uint64s(data []byte) []uint64 {
+	if len(data) == 0 {
+		return nil
+	}
+	if littleEndian && uintptr(unsafe.Pointer(&data[0]))%8 == 0 {
+		return unsafe.Slice((*uint64)(unsafe.Pointer(&data[0])), len(data)/8)
+	}
+	out := make([]uint64, len(data)/8)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint64(data[i*8:])
+	}
+	return out
+}
+
+// float64s - reinterprets bits of values as []float64
+func float64s(values []uint64) []float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*float64)(unsafe.Pointer(&values[0])), len(values))
+}
@@ -0,0 +1,176 @@
+package spellcorrect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlatFrequencies(t *testing.T) {
+	tokens := []string{"I", "program", "go", "I", "code", "and", "I", "cook", "code"}
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
+	if err := freq.TrainNgrams(strings.NewReader(strings.Join(tokens, " "))); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "ngrams.bin")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := freq.WriteFlat(f, 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	flat, err := OpenFlatFrequencies(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	words := strings.Fields(strings.ToLower(strings.Join(tokens, " ")))
+	for size := 1; size <= DefaultNgramOrder; size++ {
+		for _, gram := range TokenNgrams(words, size) {
+			if got, want := flat.Get(gram), freq.Get(gram); got != want {
+				t.Errorf("wrong prob of %v: %f, expected %f", gram, got, want)
+			}
+		}
+	}
+	for _, gram := range [][]string{{"python"}, {"i", "python"}, {"code", "i", "go"}, {"i", "code", "and", "i"}} {
+		if prob := flat.Get(gram); prob != 0 {
+			t.Errorf("prob of missing %v is %f", gram, prob)
+		}
+	}
+	if err := flat.TrainNgramsOnline([]string{"i", "go"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inMemory, err := ReadFlatFrequencies(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prob := inMemory.Get([]string{"i", "code"}); prob != freq.Get([]string{"i", "code"}) {
+		t.Errorf("wrong bigram prob %f", prob)
+	}
+	if _, err := ReadFlatFrequencies(bytes.NewReader(data[:len(data)-8])); !errors.Is(err, ErrModelTruncated) {
+		t.Errorf("expected ErrModelTruncated, got %v", err)
+	}
+}
+
+func TestSaveLoadFlatModel(t *testing.T) {
+	sc, _ := saveTestModel(t)
+
+	filename := filepath.Join(t.TempDir(), "model.flat")
+	if err := sc.SaveFlatModel(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := getSpellCorrector()
+	if err := loaded.LoadModel(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.frequencies.(*FlatFrequencies); !ok {
+		t.Fatalf("n-grams model is not flat: %T", loaded.frequencies)
+	}
+	if info := loaded.ModelInfo(); info.Version != modelVersionFlat || info.Words != 3 {
+		t.Errorf("wrong model info %+v", info)
+	}
+	if suggestions := loaded.Suggestions("pythn", 1); suggestions[0].Tokens[0] != "python" {
+		t.Errorf("wrong suggestion %v", suggestions[0].Tokens)
+	}
+	for _, gram := range [][]string{{"golang"}, {"golang", "python"}, {"java", "java", "golang"}} {
+		if got, want := loaded.frequencies.Get(gram), sc.frequencies.Get(gram); got != want {
+			t.Errorf("wrong prob of %v: %f, expected %f", gram, got, want)
+		}
+	}
+
+	// flat model is saved again in flat format and can be read from reader
+	var buf bytes.Buffer
+	if err := loaded.SaveModelTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	fromReader := getSpellCorrector()
+	if err := fromReader.LoadModelFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fromReader.frequencies.Get([]string{"golang", "python"}), sc.frequencies.Get([]string{"golang", "python"}); got != want {
+		t.Errorf("wrong bigram prob %f, expected %f", got, want)
+	}
+}
+
+func TestLoadFlatModelVerifiesNgrams(t *testing.T) {
+	sc, _ := saveTestModel(t)
+	var buf bytes.Buffer
+	if err := sc.SaveFlatModelTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	offset := binary.BigEndian.Uint64(data[16:flatModelHeaderSize])
+
+	// byte of the hashes of the first order of flat n-grams model
+	corrupt := append([]byte{}, data...)
+	corrupt[offset+64] ^= 0xff
+	filename := filepath.Join(t.TempDir(), "corrupt.flat")
+	if err := os.WriteFile(filename, corrupt, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// checksum of n-grams is not verified by default, so mapping is instant
+	loaded := getSpellCorrector()
+	if err := loaded.LoadModel(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded = getSpellCorrector()
+	loaded.SetVerifyModel(true)
+	if err := loaded.LoadModel(filename); !errors.Is(err, ErrModelChecksum) {
+		t.Errorf("expected ErrModelChecksum of mapped model, got %v", err)
+	}
+	if err := loaded.LoadModelFrom(bytes.NewReader(corrupt)); !errors.Is(err, ErrModelChecksum) {
+		t.Errorf("expected ErrModelChecksum of read model, got %v", err)
+	}
+	if loaded.HasDict() {
+		t.Errorf("corrupt model is applied")
+	}
+}
+
+func TestSaveFlatModelOverMappedFile(t *testing.T) {
+	sc, _ := saveTestModel(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "model.flat")
+	if err := sc.SaveFlatModel(filename); err != nil {
+		t.Fatal(err)
+	}
+	mapped := getSpellCorrector()
+	if err := mapped.LoadModel(filename); err != nil {
+		t.Fatal(err)
+	}
+	want := mapped.frequencies.Get([]string{"golang", "python"})
+
+	// model is saved again over the mapped file
+	other := getSpellCorrector()
+	if err := other.Train(strings.NewReader("java java java\n"), strings.NewReader("java 3\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.SaveFlatModel(filename); err != nil {
+		t.Fatal(err)
+	}
+	if got := mapped.frequencies.Get([]string{"golang", "python"}); got != want {
+		t.Errorf("mapped model is changed by saving: %f, expected %f", got, want)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files are left: %v", entries)
+	}
+}
@@ -4,52 +4,108 @@ import (
 	"bufio"
 	"compress/gzip"
 	"encoding/gob"
+	"errors"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
-	"unicode"
 
 	"github.com/segmentio/fasthash/fnv1a"
 )
 
 type ngram []uint64
 
+const (
+	// DefaultNgramOrder - max order of n-grams of models saved without it
+	DefaultNgramOrder = 3
+	// MaxNgramOrder - max supported order of n-grams
+	MaxNgramOrder = 5
+)
+
+// Frequencies - n-grams model, safe for concurrent use: Get may be called
+// from many goroutines while TrainNgramsOnline or LoadModel update the model
 type Frequencies struct {
-	MinWord      int
-	MinFreq      int
+	MinWord int
+	MinFreq int
+	Order   int
+	// UniGramProbs - vocabulary of the model with unigram probs as of
+	// training, probs of n-grams of any order are computed from Trie counts
 	UniGramProbs map[uint64]float64
 	Trie         *WordTrie
+
+	// smoothing - smoothing of probs returned by LogProb, stats are computed
+	// for it on demand
+	smoothing Smoothing
+	stats     *smoothingStats
+	// functionWords - hashes of words trained even if they are short
+	functionWords map[uint64]struct{}
+
+	mu sync.RWMutex
 }
 
-// NewFrequencis - creates new Frequencies instance
-func NewFrequencies(minWord, minFreq int) *Frequencies {
+// NewFrequencis - creates new Frequencies instance, n-grams up to order
+// words are trained
+func NewFrequencies(minWord, minFreq, order int) *Frequencies {
 	ans := Frequencies{
 		MinWord:      minWord,
 		MinFreq:      minFreq,
+		Order:        order,
 		UniGramProbs: make(map[uint64]float64),
 		Trie:         newWordTrie(0),
+		smoothing:    SmoothingNone,
 	}
 	return &ans
 }
 
-// SaveModel - saves trained speller model
+// TrainParams - returns min word length and min word freq the model was trained with
+func (o *Frequencies) TrainParams() (minWord, minFreq int) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.MinWord, o.MinFreq
+}
+
+// NgramOrder - returns max order of n-grams in the model
+func (o *Frequencies) NgramOrder() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.Order
+}
+
+// SaveModel - saves trained speller model, existing file is atomically replaced
 func (o *Frequencies) SaveModel(filename string) error {
-	f, err := os.Create(filename)
-	if err != nil {
+	return saveFile(filename, o.SaveModelTo)
+}
+
+// SaveModelTo - writes gzipped model to w, the same as SaveModel writes to file
+func (o *Frequencies) SaveModelTo(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	if err := o.EncodeModel(gz); err != nil {
 		return err
 	}
-	defer f.Close()
+	return gz.Close()
+}
 
-	w := gzip.NewWriter(f)
-	defer w.Close()
+// EncodeModel - gob-encodes model into w without compression
+func (o *Frequencies) EncodeModel(w io.Writer) error {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
 
 	runtime.GC()
 
 	enc := gob.NewEncoder(w)
-	err = enc.Encode(o)
+	err := enc.Encode(frequenciesWire{
+		MinWord:      o.MinWord,
+		MinFreq:      o.MinFreq,
+		Order:        o.Order,
+		UniGramProbs: o.UniGramProbs,
+		Ngrams:       o.Trie.wire(),
+	})
 	if err != nil {
 		return err
 	}
@@ -67,24 +123,68 @@ func (o *Frequencies) LoadModel(filename string) error {
 	}
 	defer f.Close()
 
-	gz, err := gzip.NewReader(f)
+	return o.LoadModelFrom(f)
+}
+
+// LoadModelFS - loades trained speller model from file of fsys
+func (o *Frequencies) LoadModelFS(fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return o.LoadModelFrom(f)
+}
+
+// LoadModelFrom - loades gzipped model written by SaveModelTo from r
+func (o *Frequencies) LoadModelFrom(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
 	if err != nil {
 		return err
 	}
 	defer gz.Close()
 
-	var data Frequencies
+	return o.DecodeModel(gz)
+}
+
+// DecodeModel - decodes model written by EncodeModel from r, models
+// with trie of nodes written by previous versions are decoded too
+func (o *Frequencies) DecodeModel(r io.Reader) error {
+	var data frequenciesWire
 
-	dec := gob.NewDecoder(gz)
-	err = dec.Decode(&data)
+	dec := gob.NewDecoder(r)
+	err := dec.Decode(&data)
 	if err != nil {
 		return err
 	}
 
+	trie := newWordTrie(0)
+	switch {
+	case data.Ngrams != nil:
+		trie, err = data.Ngrams.trie()
+		if err != nil {
+			return err
+		}
+	case data.Trie != nil && data.Trie.Root != nil:
+		trie = data.Trie.trie()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	o.MinFreq = data.MinFreq
 	o.MinWord = data.MinWord
-	o.Trie = data.Trie
+	o.Order = data.Order
+	if o.Order == 0 {
+		o.Order = DefaultNgramOrder
+	}
+	o.Trie = trie
 	o.UniGramProbs = data.UniGramProbs
+	if o.UniGramProbs == nil {
+		o.UniGramProbs = make(map[uint64]float64)
+	}
+	o.updateStats()
 
 	return nil
 }
@@ -93,7 +193,10 @@ func (o *Frequencies) LoadModel(filename string) error {
 func (o *Frequencies) TrainNgramsOnline(tokens []string) error {
 	var hashes []uint64
 
-	o.Trie.Root.Freq += len(tokens)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.Trie.rootFreq += len(tokens)
 
 	// for _, query := range queries
 
@@ -101,46 +204,26 @@ func (o *Frequencies) TrainNgramsOnline(tokens []string) error {
 		hashes = append(hashes, hashString(token))
 	}
 
-	for i := 1; i < 4; i++ {
+	for i := 1; i <= o.Order; i++ {
 		grams := ngrams(hashes, i)
 		for _ngram := range grams {
 			o.Trie.put(_ngram)
 		}
 	}
 
-	// update unigrams probs
+	// add new words to the vocabulary, probs are computed from counts on demand
 	for i := range hashes {
-		// need to update root.Freq and whole trie after this?
-		node := o.Trie.search([]uint64{hashes[i]})
-		o.UniGramProbs[hashes[i]] = float64(node.Freq) / float64(o.Trie.Root.Freq)
-	}
-
-	for hash := range o.UniGramProbs {
-		node := o.Trie.search([]uint64{hash})
-		o.UniGramProbs[hash] = float64(node.Freq) / float64(o.Trie.Root.Freq)
-		node.Prob = float64(node.Freq) / float64(o.Trie.Root.Freq)
-
-		if node.Children != nil {
-			go o.updateChield(node)
-		}
+		o.UniGramProbs[hashes[i]] = o.Trie.prob([]uint64{hashes[i]})
 	}
 
 	return nil
 }
 
-func (o *Frequencies) updateChield(parent *Node) {
-	for _, child := range parent.Children {
-		if child != nil {
-			child.Prob = float64(child.Freq) / float64(parent.Freq)
-			if child.Children != nil {
-				go o.updateChield(child)
-			}
-		}
-	}
-}
-
 // TrainNgrams - traning ngrams model from big corpus
 func (o *Frequencies) TrainNgrams(in io.Reader) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	if len(o.UniGramProbs) != 0 {
 		return nil
 	}
@@ -162,18 +245,17 @@ func (o *Frequencies) TrainNgrams(in io.Reader) error {
 		rawLine := scanner.Text()
 		splittedWords := strings.Fields(rawLine)
 		for _, s := range splittedWords {
-			s = strings.TrimRightFunc(s, func(r rune) bool {
-				return !unicode.IsLetter(r) && !unicode.IsNumber(r)
-			})
-			word := strings.ToLower(s)
+			// words are normalized as tokens of queries
+			word := Normalize(s)
 
 			totalWords++
 
-			if len([]rune(word)) < o.MinWord {
+			hash := hashString(word)
+			if _, ok := o.functionWords[hash]; !ok && len([]rune(word)) < o.MinWord {
 				// bl[lineHashes[len(lineHashes)-1]] = true
 				continue
 			}
-			lineHashes = append(lineHashes, hashString(word))
+			lineHashes = append(lineHashes, hash)
 			unigrams[lineHashes[len(lineHashes)-1]]++
 		}
 		hashes = append(hashes, lineHashes)
@@ -204,7 +286,7 @@ func (o *Frequencies) TrainNgrams(in io.Reader) error {
 	}
 
 	// counting N-grams probs and store them in trie
-	for i := 1; i < 4; i++ {
+	for i := 1; i <= o.Order; i++ {
 		for _, h := range hashes {
 			grams := ngrams(h, i)
 			for _ngram := range grams {
@@ -221,6 +303,7 @@ func (o *Frequencies) TrainNgrams(in io.Reader) error {
 			}
 		}
 	}
+	o.updateStats()
 	log.Println("time to load frequencies", time.Since(t))
 
 	return nil
@@ -232,40 +315,42 @@ func (o *Frequencies) Get(tokens []string) float64 {
 	for i := range tokens {
 		hashes[i] = hashString(tokens[i])
 	}
-	if len(hashes) == 1 {
-		return o.UniGramProbs[hashes[0]]
-	}
-	node := o.Trie.search(hashes)
-	if node == nil {
-		return 0.0
-	}
-	return node.Prob
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.Trie.prob(hashes)
 }
 
-// Node - trie node
+// Node - frequency and prob of n-gram found in WordTrie
 type Node struct {
-	Freq     int
-	Prob     float64
-	Children map[uint64]*Node
+	Freq int
+	Prob float64
 }
 
-// newNode - creates new node instance
-func newNode(freq int) *Node {
-	n := Node{
-		Freq:     freq,
-		Children: make(map[uint64]*Node),
-	}
-	return &n
+// trieKey - key of the node in WordTrie: index of the parent node and hash
+// of the last word
+type trieKey struct {
+	parent uint32
+	hash   uint64
 }
 
+// WordTrie - n-grams trie. Nodes are identified by index, 0 is the root,
+// children of all nodes are kept in a single hash table by index of the
+// parent and hash of the word. Probs are not stored: prob of n-gram is its
+// frequency divided by frequency of its prefix, the root for unigrams
 type WordTrie struct {
-	Root *Node
+	rootFreq int
+	freqs    []uint64
+	children map[trieKey]uint32
 }
 
 // newWordTrie - creates new WordTrie instance
 func newWordTrie(lenTokens int) *WordTrie {
 	trie := WordTrie{
-		Root: newNode(lenTokens),
+		rootFreq: lenTokens,
+		freqs:    []uint64{0},
+		children: make(map[trieKey]uint32),
 	}
 	return &trie
 }
@@ -273,32 +358,163 @@ func newWordTrie(lenTokens int) *WordTrie {
 // put - puts ngram in trie
 func (o *WordTrie) put(key ngram) {
 	//The assumption that we first add the 1gram then the 2gram etc is made
-	current := o.Root
-	var i int
-	for ; i < len(key)-1; i++ {
-		current = current.Children[key[i]]
+	var (
+		current uint32
+		ok      bool
+	)
+	for i := 0; i < len(key)-1; i++ {
+		current, ok = o.children[trieKey{current, key[i]}]
+		if !ok {
+			return
+		}
+	}
+	last := trieKey{current, key[len(key)-1]}
+	if node, ok := o.children[last]; ok {
+		o.freqs[node]++
+		return
 	}
-	node, ok := current.Children[key[i]]
-	if ok {
-		node.Freq++
-	} else {
-		node = newNode(1)
-		current.Children[key[i]] = node
+	o.children[last] = uint32(len(o.freqs))
+	o.freqs = append(o.freqs, 1)
+}
+
+// find - returns index of the node of ngram and index of its parent
+func (o *WordTrie) find(key ngram) (node, parent uint32, ok bool) {
+	for i := range key {
+		parent = node
+		node, ok = o.children[trieKey{parent, key[i]}]
+		if !ok {
+			return 0, 0, false
+		}
+	}
+	return node, parent, len(key) != 0
+}
+
+// freq - returns frequency of the node
+func (o *WordTrie) freq(node uint32) int {
+	if node == 0 {
+		return o.rootFreq
 	}
-	node.Prob = float64(node.Freq) / float64(current.Freq)
+	return int(o.freqs[node])
+}
+
+// prob - returns prob of ngram, 0 if it is not in trie
+func (o *WordTrie) prob(key ngram) float64 {
+	node, parent, ok := o.find(key)
+	if !ok {
+		return 0.0
+	}
+	return float64(o.freq(node)) / float64(o.freq(parent))
 }
 
 // search - looking for ngrams in trie
 func (o *WordTrie) search(key ngram) *Node {
-	tmp := o.Root
-	for i := 0; i < len(key); i++ {
-		if next, ok := tmp.Children[key[i]]; ok {
-			tmp = next
-		} else {
-			return nil
+	node, parent, ok := o.find(key)
+	if !ok {
+		return nil
+	}
+	return &Node{
+		Freq: o.freq(node),
+		Prob: float64(o.freq(node)) / float64(o.freq(parent)),
+	}
+}
+
+// size - returns amount of n-grams in trie
+func (o *WordTrie) size() int {
+	return len(o.freqs) - 1
+}
+
+// trieWire - gob representation of WordTrie, parent of each node
+// precedes it, so nodes are restored in the same order
+type trieWire struct {
+	RootFreq int
+	Parents  []uint32
+	Hashes   []uint64
+	Freqs    []uint64
+}
+
+// wire - returns gob representation of trie
+func (o *WordTrie) wire() *trieWire {
+	w := &trieWire{
+		RootFreq: o.rootFreq,
+		Parents:  make([]uint32, o.size()),
+		Hashes:   make([]uint64, o.size()),
+		Freqs:    o.freqs[1:],
+	}
+	for key, node := range o.children {
+		w.Parents[node-1] = key.parent
+		w.Hashes[node-1] = key.hash
+	}
+	return w
+}
+
+// trie - restores WordTrie from gob representation
+func (o *trieWire) trie() (*WordTrie, error) {
+	if len(o.Parents) != len(o.Freqs) || len(o.Hashes) != len(o.Freqs) {
+		return nil, errors.New("n-grams trie sizes mismatch")
+	}
+	trie := &WordTrie{
+		rootFreq: o.RootFreq,
+		freqs:    make([]uint64, len(o.Freqs)+1),
+		children: make(map[trieKey]uint32, len(o.Freqs)),
+	}
+	copy(trie.freqs[1:], o.Freqs)
+	for i := range o.Parents {
+		if int(o.Parents[i]) > i {
+			return nil, errors.New("n-grams trie node precedes its parent")
+		}
+		trie.children[trieKey{o.Parents[i], o.Hashes[i]}] = uint32(i + 1)
+	}
+	return trie, nil
+}
+
+// frequenciesWire - gob representation of Frequencies. Trie holds trie of
+// nodes written by previous versions, Ngrams is written now. Order is 0
+// in models of previous versions, they are of DefaultNgramOrder
+type frequenciesWire struct {
+	MinWord      int
+	MinFreq      int
+	Order        int
+	UniGramProbs map[uint64]float64
+	Trie         *legacyTrie
+	Ngrams       *trieWire
+}
+
+// legacyTrie - trie of nodes with child maps written by previous versions
+type legacyTrie struct {
+	Root *legacyNode
+}
+
+type legacyNode struct {
+	Freq     int
+	Prob     float64
+	Children map[uint64]*legacyNode
+}
+
+// trie - converts legacy trie to WordTrie
+func (o *legacyTrie) trie() *WordTrie {
+	trie := newWordTrie(o.Root.Freq)
+	parents := []*legacyNode{o.Root}
+	ids := []uint32{0}
+	for len(parents) != 0 {
+		var (
+			nextParents []*legacyNode
+			nextIDs     []uint32
+		)
+		for i, parent := range parents {
+			for hash, child := range parent.Children {
+				if child == nil {
+					continue
+				}
+				id := uint32(len(trie.freqs))
+				trie.children[trieKey{ids[i], hash}] = id
+				trie.freqs = append(trie.freqs, uint64(child.Freq))
+				nextParents = append(nextParents, child)
+				nextIDs = append(nextIDs, id)
+			}
 		}
+		parents, ids = nextParents, nextIDs
 	}
-	return tmp
+	return trie
 }
 
 // hashString - hashes string
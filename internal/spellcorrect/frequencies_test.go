@@ -1,6 +1,11 @@
 package spellcorrect
 
 import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"math/rand"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -8,7 +13,7 @@ import (
 func TestFrequencies(t *testing.T) {
 	tokens := []string{"I", "program", "go", "I", "code", "and", "I", "cook", "code"}
 	in := strings.NewReader(strings.Join(tokens, " "))
-	freq := NewFrequencies(0, 0)
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
 	if err := freq.TrainNgrams(in); err != nil {
 		t.Errorf(err.Error())
 		return
@@ -31,6 +36,90 @@ func TestFrequencies(t *testing.T) {
 
 }
 
+func TestFrequenciesOnline(t *testing.T) {
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
+	if err := freq.TrainNgrams(strings.NewReader("red table\nblue table\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := freq.TrainNgramsOnline([]string{"green", "table"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// probs of words which are not learned follow the total count
+	for word, expected := range map[string]float64{"red": 1.0 / 6, "table": 3.0 / 6, "green": 1.0 / 6} {
+		if prob := freq.Get([]string{word}); math.Abs(prob-expected) > 1e-9 {
+			t.Errorf("unigram %q prob %f, expected %f", word, prob, expected)
+		}
+	}
+}
+
+func TestFrequenciesNormalized(t *testing.T) {
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
+	if err := freq.TrainNgrams(strings.NewReader("«Желтая скатерть» (для стола)\n")); err != nil {
+		t.Fatal(err)
+	}
+	tokens, _ := NewSimpleTokenizer().Tokens(strings.NewReader("желтая скатерть для стола"))
+	for _, gram := range TokenNgrams(tokens, 3) {
+		if freq.Get(gram) == 0 {
+			t.Errorf("n-gram %q of the query is not found", gram)
+		}
+	}
+
+	// queries learned in auto train mode are normalized too
+	sc := NewSpellCorrector(NewSimpleTokenizer(), freq, []float64{1, 5, 4}, false, 1, 1.5)
+	sc.addWordToModel("«красная скатерть»")
+	if freq.Get([]string{"красная", "скатерть"}) == 0 {
+		t.Errorf("learned n-gram is not found")
+	}
+}
+
+func TestFrequenciesOrder(t *testing.T) {
+	text := "red cotton table cloth\nblue cotton table cloth\nred cotton bed sheet\n"
+	freq := NewFrequencies(0, 0, 4)
+	if err := freq.TrainNgrams(strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	if prob := freq.Get([]string{"red", "cotton", "table", "cloth"}); prob != 1 {
+		t.Errorf("wrong 4-gram prob %f", prob)
+	}
+	// 4-gram context tells which cloth follows
+	if a, b := freq.LogProb([]string{"red", "cotton", "table", "cloth"}), freq.LogProb([]string{"red", "cotton", "bed", "cloth"}); a <= b {
+		t.Errorf("4-gram context is not used: %f <= %f", a, b)
+	}
+
+	var buf bytes.Buffer
+	if err := freq.EncodeModel(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewFrequencies(0, 0, DefaultNgramOrder)
+	if err := decoded.DecodeModel(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.NgramOrder() != 4 {
+		t.Errorf("wrong order of decoded model %d", decoded.NgramOrder())
+	}
+
+	buf.Reset()
+	if err := freq.WriteFlat(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	flat, err := ReadFlatFrequencies(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flat.NgramOrder() != 4 || flat.Get([]string{"blue", "cotton", "table", "cloth"}) != 1 {
+		t.Errorf("4-grams are not written to flat model")
+	}
+
+	unigrams := NewFrequencies(0, 0, 1)
+	if err := unigrams.TrainNgrams(strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	if unigrams.Get([]string{"red", "cotton"}) != 0 || unigrams.Get([]string{"red"}) == 0 {
+		t.Errorf("unigrams model has bigrams or misses unigrams")
+	}
+}
+
 func TestWordTrie(t *testing.T) {
 	words := []uint64{
 		1, 2, 3, 4, 5, 6, 1, 2,
@@ -67,3 +156,169 @@ func TestWordTrie(t *testing.T) {
 		return
 	}
 }
+
+func TestDecodeLegacyTrie(t *testing.T) {
+	tokens := []string{"I", "program", "go", "I", "code", "and", "I", "cook", "code"}
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
+	if err := freq.TrainNgrams(strings.NewReader(strings.Join(tokens, " "))); err != nil {
+		t.Fatal(err)
+	}
+
+	// model written by previous versions with trie of nodes
+	legacy := &legacyTrie{Root: &legacyNode{Freq: freq.Trie.rootFreq, Children: map[uint64]*legacyNode{}}}
+	words := strings.Fields(strings.ToLower(strings.Join(tokens, " ")))
+	hashes := make([]uint64, len(words))
+	for i := range words {
+		hashes[i] = hashString(words[i])
+	}
+	for size := 1; size <= DefaultNgramOrder; size++ {
+		for gram := range ngrams(hashes, size) {
+			legacy.put(gram)
+		}
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(frequenciesWire{
+		MinWord:      freq.MinWord,
+		MinFreq:      freq.MinFreq,
+		UniGramProbs: freq.UniGramProbs,
+		Trie:         legacy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := NewFrequencies(0, 0, MaxNgramOrder)
+	if err := decoded.DecodeModel(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Order != DefaultNgramOrder {
+		t.Errorf("wrong order of legacy model %d", decoded.Order)
+	}
+	if decoded.Trie.size() != freq.Trie.size() {
+		t.Fatalf("wrong amount of n-grams %d, expected %d", decoded.Trie.size(), freq.Trie.size())
+	}
+	for size := 1; size <= DefaultNgramOrder; size++ {
+		for _, gram := range TokenNgrams(words, size) {
+			if got, want := decoded.Get(gram), freq.Get(gram); got != want {
+				t.Errorf("wrong prob of %v: %f, expected %f", gram, got, want)
+			}
+		}
+	}
+}
+
+// put - puts ngram in trie of nodes the way previous versions did
+func (o *legacyTrie) put(key ngram) {
+	current := o.Root
+	var i int
+	for ; i < len(key)-1; i++ {
+		current = current.Children[key[i]]
+	}
+	node, ok := current.Children[key[i]]
+	if ok {
+		node.Freq++
+	} else {
+		node = &legacyNode{Freq: 1, Children: make(map[uint64]*legacyNode)}
+		current.Children[key[i]] = node
+	}
+	node.Prob = float64(node.Freq) / float64(current.Freq)
+}
+
+// search - looking for ngrams in trie of nodes the way previous versions did
+func (o *legacyTrie) search(key ngram) *legacyNode {
+	tmp := o.Root
+	for i := 0; i < len(key); i++ {
+		next, ok := tmp.Children[key[i]]
+		if !ok {
+			return nil
+		}
+		tmp = next
+	}
+	return tmp
+}
+
+// benchmarkNgrams - returns n-grams of zipf distributed words
+func benchmarkNgrams(words int) []ngram {
+	rnd := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rnd, 1.1, 1, 50000)
+	hashes := make([]uint64, words)
+	for i := range hashes {
+		hashes[i] = zipf.Uint64() + 1
+	}
+
+	var grams []ngram
+	for size := 1; size <= DefaultNgramOrder; size++ {
+		for i := 0; i+size <= len(hashes); i++ {
+			grams = append(grams, hashes[i:i+size])
+		}
+	}
+	return grams
+}
+
+// heapInUse - returns heap size after garbage collection
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+func BenchmarkWordTriePut(b *testing.B) {
+	grams := benchmarkNgrams(300000)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		before := heapInUse()
+		trie := newWordTrie(300000)
+		for _, gram := range grams {
+			trie.put(gram)
+		}
+		b.ReportMetric(float64(heapInUse()-before)/float64(trie.size()), "bytes/ngram")
+		runtime.KeepAlive(trie)
+	}
+}
+
+func BenchmarkLegacyTriePut(b *testing.B) {
+	grams := benchmarkNgrams(300000)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		before := heapInUse()
+		trie := &legacyTrie{Root: &legacyNode{Freq: 300000, Children: map[uint64]*legacyNode{}}}
+		for _, gram := range grams {
+			trie.put(gram)
+		}
+		size := trie.Root.count()
+		b.ReportMetric(float64(heapInUse()-before)/float64(size), "bytes/ngram")
+		runtime.KeepAlive(trie)
+	}
+}
+
+func (o *legacyNode) count() int {
+	n := len(o.Children)
+	for _, child := range o.Children {
+		n += child.count()
+	}
+	return n
+}
+
+func BenchmarkWordTrieSearch(b *testing.B) {
+	grams := benchmarkNgrams(300000)
+	trie := newWordTrie(300000)
+	for _, gram := range grams {
+		trie.put(gram)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.prob(grams[n%len(grams)])
+	}
+}
+
+func BenchmarkLegacyTrieSearch(b *testing.B) {
+	grams := benchmarkNgrams(300000)
+	trie := &legacyTrie{Root: &legacyNode{Freq: 300000, Children: map[uint64]*legacyNode{}}}
+	for _, gram := range grams {
+		trie.put(gram)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.search(grams[n%len(grams)])
+	}
+}
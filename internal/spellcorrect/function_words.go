@@ -0,0 +1,77 @@
+package spellcorrect
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/eskriett/strmet"
+)
+
+// DefaultFunctionWords - prepositions, conjunctions and particles of Russian
+// and English, candidates of short words which are corrected by context
+var DefaultFunctionWords = strings.Fields(`
+	а без в во да для до за и из или к ко ли на над не ни но о об обо от по под
+	при про с со у что же бы то как
+	a an and as at but by for from if in into of on or the to with`)
+
+// functionWordsTrainer - n-grams model which keeps function words in training
+type functionWordsTrainer interface {
+	SetFunctionWords(words []string)
+}
+
+// SetFunctionWords - sets words which are trained in n-grams even if they
+// are shorter than min word length, so they can be corrected by context
+func (o *Frequencies) SetFunctionWords(words []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.functionWords = make(map[uint64]struct{}, len(words))
+	for _, word := range words {
+		o.functionWords[hashString(Normalize(word))] = struct{}{}
+	}
+}
+
+// SetFunctionWords - sets candidates of short words, words shorter than
+// LookupParams.ShortWordLength get function words within lookup edit
+// distance as candidates unless they are function words themselves
+func (o *SpellCorrector) SetFunctionWords(words []string) {
+	o.functionWords = make(map[string]struct{}, len(words))
+	o.functionWordList = nil
+	for _, word := range words {
+		word = Normalize(word)
+		if _, ok := o.functionWords[word]; !ok {
+			o.functionWords[word] = struct{}{}
+			o.functionWordList = append(o.functionWordList, word)
+		}
+	}
+	if t, ok := o.frequencies.(functionWordsTrainer); ok {
+		t.SetFunctionWords(words)
+	}
+}
+
+// hasLetter - reports whether the token has at least one letter
+func hasLetter(token string) bool {
+	return strings.IndexFunc(token, unicode.IsLetter) >= 0
+}
+
+// functionWordCandidates - returns function words within edit distance of
+// the token and their distances
+func (o *SpellCorrector) functionWordCandidates(token string) ([]string, []int) {
+	if _, ok := o.functionWords[token]; ok {
+		return nil, nil
+	}
+
+	var (
+		words     []string
+		distances []int
+	)
+	runes := []rune(token)
+	for _, word := range o.functionWordList {
+		distance := strmet.DamerauLevenshteinRunes(runes, []rune(word), o.params.EditDistance)
+		if distance > 0 {
+			words = append(words, word)
+			distances = append(distances, distance)
+		}
+	}
+	return words, distances
+}
@@ -0,0 +1,50 @@
+package spellcorrect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFunctionWordsTrained(t *testing.T) {
+	freq := NewFrequencies(4, 0, DefaultNgramOrder)
+	freq.SetFunctionWords([]string{"for"})
+	if err := freq.TrainNgrams(strings.NewReader("table cloth for the kitchen")); err != nil {
+		t.Fatal(err)
+	}
+	if freq.Get([]string{"cloth", "for", "kitchen"}) == 0 {
+		t.Errorf("function word is not trained")
+	}
+	if freq.Get([]string{"the"}) != 0 {
+		t.Errorf("short word is trained")
+	}
+}
+
+func TestFunctionWordCandidates(t *testing.T) {
+	sc := getSpellCorrector()
+	sc.SetFunctionWords([]string{"for", "of", "or", "the"})
+
+	words, distances := sc.functionWordCandidates("fr")
+	if strings.Join(words, " ") != "for or" || len(distances) != 2 || distances[0] != 1 {
+		t.Errorf("wrong candidates %q %v", words, distances)
+	}
+	if words, _ := sc.functionWordCandidates("or"); words != nil {
+		t.Errorf("function word gets candidates %q", words)
+	}
+}
+
+func TestFunctionWordCandidatesLookup(t *testing.T) {
+	sc := getSpellCorrector()
+	sc.SetFunctionWords([]string{"a", "i", "of"})
+
+	candidates, _, _ := sc.lookupTokens([]string{"—", "of"}, nil)
+	if strings.Join(candidates[0], " ") != "—" {
+		t.Errorf("token without letters gets candidates %q", candidates[0])
+	}
+	seen := make(map[string]bool)
+	for _, word := range candidates[1] {
+		if seen[word] {
+			t.Errorf("duplicate candidate %q in %q", word, candidates[1])
+		}
+		seen[word] = true
+	}
+}
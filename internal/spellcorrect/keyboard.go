@@ -0,0 +1,151 @@
+package spellcorrect
+
+import (
+	"sort"
+
+	"github.com/eskriett/spell"
+)
+
+// DefaultAdjacentKeyCost - default cost of substitution of adjacent keys
+const DefaultAdjacentKeyCost = 0.5
+
+// numberKeys - keys of number row following the first key of layout
+const numberKeys = "1234567890-="
+
+// keyboardRows - returns rows of keys of both sides of the layouts which
+// keys are in order of QWERTYKeys: the key left of number row and rows of
+// 12, 11 and 10 letters. Each next row is shifted right by half of the key,
+// space is a gap without key. Layouts of other amount of keys have no rows
+func keyboardRows(layouts []Layout) [][]string {
+	var rows [][]string
+	for _, layout := range layouts {
+		for _, keys := range layout.keys {
+			if len(keys) != 1+12+11+10 {
+				continue
+			}
+			rows = append(rows, []string{
+				string(keys[0]) + numberKeys,
+				" " + string(keys[1:13]),
+				" " + string(keys[13:24]),
+				" " + string(keys[24:]),
+			})
+		}
+	}
+	return rows
+}
+
+// keyboard - weighted Damerau-Levenshtein distance where substitution
+// of adjacent keys is cheaper than other edits which cost 1
+type keyboard struct {
+	adjacent map[[2]rune]struct{}
+	cost     float64
+}
+
+// newKeyboard - returns distance with given cost of substitution of
+// adjacent keys of the rows of layouts. Key is adjacent to its neighbours
+// in the row, to the same and the next keys of the previous row and to
+// the same and the previous keys of the next row
+func newKeyboard(layouts [][]string, cost float64) *keyboard {
+	o := &keyboard{
+		adjacent: make(map[[2]rune]struct{}),
+		cost:     cost,
+	}
+	for _, rows := range layouts {
+		keys := make([][]rune, len(rows))
+		for i := range rows {
+			keys[i] = []rune(rows[i])
+		}
+		for i := range keys {
+			for j := range keys[i] {
+				o.link(keys[i], j, keys[i], j+1)
+				if i+1 < len(keys) {
+					o.link(keys[i], j, keys[i+1], j-1)
+					o.link(keys[i], j, keys[i+1], j)
+				}
+			}
+		}
+	}
+	return o
+}
+
+// link - makes a-th key of row and b-th key of next adjacent if they exist
+func (o *keyboard) link(row []rune, a int, next []rune, b int) {
+	if b < 0 || b >= len(next) || row[a] == ' ' || next[b] == ' ' {
+		return
+	}
+	o.adjacent[[2]rune{row[a], next[b]}] = struct{}{}
+	o.adjacent[[2]rune{next[b], row[a]}] = struct{}{}
+}
+
+// substitution - returns cost of substitution of rune a by b
+func (o *keyboard) substitution(a, b rune) float64 {
+	switch {
+	case a == b:
+		return 0
+	case o == nil:
+		return 1
+	}
+	if _, ok := o.adjacent[[2]rune{a, b}]; ok {
+		return o.cost
+	}
+	return 1
+}
+
+// distance - returns weighted optimal string alignment distance between
+// words: insertions, deletions and transpositions of adjacent runes cost 1
+func (o *keyboard) distance(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	// three last rows of the distances matrix
+	prev2 := make([]float64, len(rb)+1)
+	prev := make([]float64, len(rb)+1)
+	cur := make([]float64, len(rb)+1)
+	for j := range prev {
+		prev[j] = float64(j)
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = float64(i)
+		for j := 1; j <= len(rb); j++ {
+			d := prev[j-1] + o.substitution(ra[i-1], rb[j-1])
+			if v := prev[j] + 1; v < d {
+				d = v
+			}
+			if v := cur[j-1] + 1; v < d {
+				d = v
+			}
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				if v := prev2[j-2] + 1; v < d {
+					d = v
+				}
+			}
+			cur[j] = d
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// SetAdjacentKeyCost - sets cost of substitution of adjacent keys of
+// keyboard layouts in weighted edit distance which ranks candidates and
+// penalizes their scores, cost 1 makes all substitutions equal
+func (o *SpellCorrector) SetAdjacentKeyCost(cost float64) {
+	o.keyboard = newKeyboard(keyboardRows(o.layouts), cost)
+}
+
+// weighSuggestions - sorts suggestions by weighted edit distance to the
+// token keeping order of equally distant ones, returns their distances
+func (o *SpellCorrector) weighSuggestions(token string, suggestions spell.SuggestionList) []float64 {
+	distances := make(map[string]float64, len(suggestions))
+	for _, suggestion := range suggestions {
+		distances[suggestion.Word] = o.keyboard.distance(token, suggestion.Word)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return distances[suggestions[i].Word] < distances[suggestions[j].Word]
+	})
+
+	weighted := make([]float64, len(suggestions))
+	for i := range suggestions {
+		weighted[i] = distances[suggestions[i].Word]
+	}
+	return weighted
+}
@@ -0,0 +1,67 @@
+package spellcorrect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyboardDistance(t *testing.T) {
+	kb := newKeyboard(keyboardRows(DefaultLayouts()), DefaultAdjacentKeyCost)
+	for _, test := range []struct {
+		a, b     string
+		distance float64
+	}{
+		{"мыло", "мыло", 0},
+		{"мыло", "мвло", 0.5},  // adjacent keys of the row
+		{"мыло", "мцло", 0.5},  // adjacent keys of the rows
+		{"мыло", "мпло", 1},    // distant keys
+		{"мыло", "мылол", 1},   // insertion
+		{"мыло", "мыл", 1},     // deletion
+		{"мыло", "мылл", 0.5},  // adjacent keys of the row
+		{"мыло", "мылш", 0.5},  // adjacent keys of the rows
+		{"мыло", "мылщ", 1},    // distant keys
+		{"мыло", "ымло", 1},    // transposition
+		{"desk", "dask", 1},    // distant keys
+		{"desk", "dwsk", 0.5},  // adjacent keys of the rows
+		{"desk", "", 4},        // deletions
+		{"", "desk", 4},        // insertions
+		{"мыло", "вмыдо", 1.5}, // insertion and adjacent keys
+		{"ёлка", "1лка", 0.5},  // adjacent keys of the row
+		{"ёлка", "йлка", 1},    // distant keys
+		{"ёлка", "елка", 1},    // distant keys
+		{"`", "1", 0.5},        // adjacent keys of the row
+	} {
+		if distance := kb.distance(test.a, test.b); distance != test.distance {
+			t.Errorf("distance between %q and %q is %v, expected %v", test.a, test.b, distance, test.distance)
+		}
+	}
+}
+
+func TestAdjacentKeysRanking(t *testing.T) {
+	sc := NewSpellCorrector(NewSimpleTokenizer(), NewFrequencies(0, 0, DefaultNgramOrder), []float64{1, 5, 4}, false, 1, 1.5)
+	if err := sc.LoadFreqDict(strings.NewReader("кол 100\nвол 10\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	allSuggestions, dist, edits := sc.lookupTokens([]string{"ыол"}, nil)
+	if allSuggestions[0][0] != "вол" || dist[0]["вол"] != DefaultAdjacentKeyCost || edits[0]["вол"] != 1 {
+		t.Errorf("wrong candidates %q, distances %v", allSuggestions[0], dist)
+	}
+
+	sc.SetAdjacentKeyCost(1)
+	allSuggestions, dist, _ = sc.lookupTokens([]string{"ыол"}, nil)
+	if allSuggestions[0][0] != "кол" || dist[0]["кол"] != 1 {
+		t.Errorf("wrong candidates of equal substitutions %q, distances %v", allSuggestions[0], dist)
+	}
+
+	// keys are adjacent on keyboards of the layouts only
+	sc.SetAdjacentKeyCost(DefaultAdjacentKeyCost)
+	sc.SetLayouts(nil)
+	if distance := sc.keyboard.distance("ыол", "вол"); distance != 1 {
+		t.Errorf("distance without layouts is %v", distance)
+	}
+	sc.SetLayouts(DefaultLayouts())
+	if distance := sc.keyboard.distance("ыол", "вол"); distance != DefaultAdjacentKeyCost {
+		t.Errorf("distance of layouts is %v", distance)
+	}
+}
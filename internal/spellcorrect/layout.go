@@ -0,0 +1,145 @@
+package spellcorrect
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Keys of QWERTY and ЙЦУКЕН keyboard layouts in the same order
+const (
+	QWERTYKeys = "`qwertyuiop[]asdfghjkl;'zxcvbnm,./"
+	JCUKENKeys = "ёйцукенгшщзхъфывапролджэячсмитьбю."
+)
+
+var errLayoutKeys = errors.New("keyboard layouts must have the same non zero amount of keys")
+
+// Layout - conversion of words typed with wrong keyboard layout, runes of
+// one layout are converted to runes of the same keys of the other one
+type Layout struct {
+	forward  map[rune]rune
+	backward map[rune]rune
+	// keys - keys of both layouts in the given order
+	keys [2][]rune
+}
+
+// NewLayout - returns conversion between layouts given by their keys in
+// the same order, e.g. QWERTYKeys and JCUKENKeys. Keys are lowercase as
+// tokens are, conversion works both ways
+func NewLayout(from, to string) (Layout, error) {
+	if from == "" || utf8.RuneCountInString(from) != utf8.RuneCountInString(to) {
+		return Layout{}, errLayoutKeys
+	}
+
+	toKeys := []rune(to)
+	layout := Layout{
+		forward:  make(map[rune]rune, len(from)),
+		backward: make(map[rune]rune, len(to)),
+		keys:     [2][]rune{[]rune(from), toKeys},
+	}
+	for i, r := range []rune(from) {
+		layout.forward[r] = toKeys[i]
+		layout.backward[toKeys[i]] = r
+	}
+	return layout, nil
+}
+
+// DefaultLayouts - returns conversion between QWERTY and ЙЦУКЕН layouts
+func DefaultLayouts() []Layout {
+	layout, _ := NewLayout(QWERTYKeys, JCUKENKeys)
+	return []Layout{layout}
+}
+
+// Convert - returns the word typed with the other layout, runes which are
+// not keys of the layout and not letters are kept. Returns false if the
+// word has letters of both layouts or letters of neither of them
+func (o Layout) Convert(word string) (string, bool) {
+	if converted, ok := convertKeys(word, o.forward); ok {
+		return converted, true
+	}
+	return convertKeys(word, o.backward)
+}
+
+// convertKeys - replaces runes of the word by keys, the word must have
+// at least one key and no letters which are not keys
+func convertKeys(word string, keys map[rune]rune) (string, bool) {
+	var (
+		b         strings.Builder
+		converted bool
+	)
+	b.Grow(len(word))
+	for _, r := range word {
+		key, ok := keys[r]
+		switch {
+		case ok:
+			b.WriteRune(key)
+			converted = true
+		case unicode.IsLetter(r):
+			return "", false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), converted
+}
+
+// SetLayouts - sets keyboard layouts conversions, tokens converted to
+// dictionary words are candidates without edit distance penalty and
+// compete with other candidates by n-grams probs. Keys of the layouts are
+// adjacent keys of weighted edit distance. Empty layouts disable
+// conversion, QWERTY and ЙЦУКЕН are converted by default
+func (o *SpellCorrector) SetLayouts(layouts []Layout) {
+	o.layouts = layouts
+	cost := DefaultAdjacentKeyCost
+	if o.keyboard != nil {
+		cost = o.keyboard.cost
+	}
+	o.keyboard = newKeyboard(keyboardRows(layouts), cost)
+}
+
+// layoutCandidates - returns dictionary words which the token or the word
+// it is normalized from are converted to by keyboard layouts. Punctuation
+// of the word may be keys of letters, e.g. "," typed for "б". Dictionary
+// words are typed with the right layout, so they are not converted
+func (o *SpellCorrector) layoutCandidates(token, word string) []string {
+	if entry, _ := o.spell.GetEntry(token); entry != nil {
+		return nil
+	}
+
+	var words []string
+	for _, layout := range o.layouts {
+		for _, typed := range [...]string{strings.ToLower(word), token} {
+			converted, ok := layout.Convert(typed)
+			if !ok || converted == token || contains(words, converted) {
+				continue
+			}
+			if entry, _ := o.spell.GetEntry(converted); entry != nil {
+				words = append(words, converted)
+			}
+		}
+	}
+	return words
+}
+
+// LayoutConverts - reports whether the word as typed, with its punctuation,
+// is converted to the correction by one of keyboard layouts
+func (o *SpellCorrector) LayoutConverts(word, correction string) bool {
+	word = strings.ToLower(word)
+	for _, layout := range o.layouts {
+		if converted, ok := layout.Convert(word); ok && converted == correction {
+			return true
+		}
+	}
+	return false
+}
+
+// contains - reports whether the words have the word
+func contains(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package spellcorrect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLayoutConvert(t *testing.T) {
+	layout := DefaultLayouts()[0]
+	for word, expected := range map[string]string{
+		"ckjy":    "слон",
+		"ghbdtn":  "привет",
+		"j,tl":    "обед",
+		"слон":    "ckjy",
+		"ntcn-12": "тест-12",
+	} {
+		if converted, ok := layout.Convert(word); !ok || converted != expected {
+			t.Errorf("wrong conversion of %q: %q, expected %q", word, converted, expected)
+		}
+	}
+	for _, word := range []string{"ckjyслон", "123", "straße"} {
+		if converted, ok := layout.Convert(word); ok {
+			t.Errorf("%q is converted to %q", word, converted)
+		}
+	}
+
+	if _, err := NewLayout("qwe", "йц"); err == nil {
+		t.Errorf("expected error for layouts of different length")
+	}
+}
+
+func TestLayoutCandidates(t *testing.T) {
+	sentences := strings.Repeat("купить слон игрушка\nкупить плюшевый слон\n", 3)
+	sc := NewSpellCorrector(NewSimpleTokenizer(), NewFrequencies(0, 0, DefaultNgramOrder), []float64{1, 5, 4}, false, 1, 1.5)
+	if err := sc.Train(strings.NewReader(sentences), strings.NewReader("купить 10\nслон 10\nигрушка 10\nплюшевый 10\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	suggestion := sc.SpellCorrect("купить ckjy игрушка")[0]
+	if strings.Join(suggestion.Tokens, " ") != "купить слон игрушка" {
+		t.Errorf("wrong correction %q", suggestion.Tokens)
+	}
+
+	sc.SetLayouts(nil)
+	suggestion = sc.SpellCorrect("купить ckjy игрушка")[0]
+	if strings.Join(suggestion.Tokens, " ") != "купить ckjy игрушка" {
+		t.Errorf("wrong correction without layouts %q", suggestion.Tokens)
+	}
+}
+
+func TestLayoutCandidatesOfDictionaryWords(t *testing.T) {
+	sentences := strings.Repeat("her cat\nмоих рук\nрук моих\n", 3)
+	sc := NewSpellCorrector(NewSimpleTokenizer(), NewFrequencies(0, 0, DefaultNgramOrder), []float64{1, 5, 4}, false, 1, 1.5)
+	if err := sc.Train(strings.NewReader(sentences), strings.NewReader("her 1\ncat 1\nмоих 10\nрук 100\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// "her" is "рук" typed with English layout, but it is a valid word
+	if candidates := sc.layoutCandidates("her", "her"); len(candidates) != 0 {
+		t.Errorf("dictionary word is converted to %q", candidates)
+	}
+	for _, smoothing := range []Smoothing{SmoothingNone, StupidBackoff} {
+		sc.SetSmoothing(smoothing)
+		if suggestion := sc.SpellCorrect("her")[0]; strings.Join(suggestion.Tokens, " ") != "her" {
+			t.Errorf("wrong correction of valid word %q", suggestion.Tokens)
+		}
+	}
+}
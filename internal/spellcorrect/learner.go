@@ -0,0 +1,142 @@
+package spellcorrect
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// LearnPolicy - behaviour of the learner when its queue is full
+type LearnPolicy int
+
+const (
+	// LearnDrop - query is dropped and counted in LearnerStats.Dropped
+	LearnDrop LearnPolicy = iota
+	// LearnBlock - caller waits until there is free space in the queue
+	LearnBlock
+)
+
+// LearnerParams - parameters of the auto train mode learner
+type LearnerParams struct {
+	QueueSize int
+	Policy    LearnPolicy
+}
+
+// DefaultLearnerParams - returns default learner parameters
+func DefaultLearnerParams() LearnerParams {
+	return LearnerParams{
+		QueueSize: 1024,
+		Policy:    LearnDrop,
+	}
+}
+
+// LearnerStats - metrics of the auto train mode learner
+type LearnerStats struct {
+	QueueDepth int    // queries waiting in the queue
+	QueueSize  int    // capacity of the queue
+	Learned    uint64 // queries the model was trained on
+	Dropped    uint64 // queries dropped because queue was full or learner closed
+}
+
+// learnItem - query to train on or flush request if done is not nil
+type learnItem struct {
+	query string
+	done  chan struct{}
+}
+
+// learner - single goroutine which trains model on queries from bounded queue
+type learner struct {
+	// counters are first to be 64-bit aligned for atomic operations
+	learned uint64
+	dropped uint64
+
+	queue  chan learnItem
+	policy LearnPolicy
+	learn  func(query string)
+
+	mu       sync.RWMutex // guards closed, held for reading while sending to queue
+	closed   bool
+	finished chan struct{}
+}
+
+// newLearner - creates learner and starts its goroutine
+func newLearner(params LearnerParams, learn func(query string)) *learner {
+	l := &learner{
+		queue:    make(chan learnItem, params.QueueSize),
+		policy:   params.Policy,
+		learn:    learn,
+		finished: make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+func (o *learner) run() {
+	defer close(o.finished)
+	for item := range o.queue {
+		if item.done != nil {
+			close(item.done)
+			continue
+		}
+		o.learn(item.query)
+		atomic.AddUint64(&o.learned, 1)
+	}
+}
+
+// add - puts query in the queue according to policy
+func (o *learner) add(query string) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.closed {
+		atomic.AddUint64(&o.dropped, 1)
+		return
+	}
+
+	item := learnItem{query: query}
+	if o.policy == LearnBlock {
+		o.queue <- item
+		return
+	}
+
+	select {
+	case o.queue <- item:
+	default:
+		atomic.AddUint64(&o.dropped, 1)
+	}
+}
+
+// flush - waits until all queries queued before the call are learned
+func (o *learner) flush() {
+	o.mu.RLock()
+	if o.closed {
+		o.mu.RUnlock()
+		return
+	}
+	done := make(chan struct{})
+	o.queue <- learnItem{done: done}
+	o.mu.RUnlock()
+
+	<-done
+}
+
+// close - stops accepting queries and waits until queued ones are learned
+func (o *learner) close() {
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.queue)
+	}
+	o.mu.Unlock()
+
+	<-o.finished
+}
+
+// stats - returns current metrics
+func (o *learner) stats() LearnerStats {
+	return LearnerStats{
+		QueueDepth: len(o.queue),
+		QueueSize:  cap(o.queue),
+		Learned:    atomic.LoadUint64(&o.learned),
+		Dropped:    atomic.LoadUint64(&o.dropped),
+	}
+}
@@ -0,0 +1,73 @@
+package spellcorrect
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLearnerDropPolicy(t *testing.T) {
+	release := make(chan struct{})
+	taken := make(chan struct{}, 4)
+	var learned []string
+	l := newLearner(LearnerParams{QueueSize: 2, Policy: LearnDrop}, func(query string) {
+		taken <- struct{}{}
+		<-release
+		learned = append(learned, query)
+	})
+
+	// first query is taken by the learner, next two fill the queue
+	l.add("a")
+	<-taken
+	l.add("b")
+	l.add("c")
+	l.add("d")
+
+	stats := l.stats()
+	if stats.QueueDepth != 2 || stats.QueueSize != 2 || stats.Dropped != 1 {
+		t.Errorf("wrong stats %+v", stats)
+		return
+	}
+
+	close(release)
+	l.flush()
+	if stats := l.stats(); stats.Learned != 3 || stats.QueueDepth != 0 {
+		t.Errorf("wrong stats after flush %+v", stats)
+		return
+	}
+	if len(learned) != 3 || learned[2] != "c" {
+		t.Errorf("wrong learned queries %v", learned)
+	}
+
+	l.close()
+	l.add("e")
+	if stats := l.stats(); stats.Dropped != 2 {
+		t.Errorf("query added after close is not dropped %+v", stats)
+	}
+}
+
+func TestLearnerBlockPolicy(t *testing.T) {
+	var mu sync.Mutex
+	var learned int
+	l := newLearner(LearnerParams{QueueSize: 1, Policy: LearnBlock}, func(query string) {
+		mu.Lock()
+		learned++
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				l.add("query")
+			}
+		}()
+	}
+	wg.Wait()
+	l.close()
+
+	if stats := l.stats(); stats.Learned != 200 || stats.Dropped != 0 || learned != 200 {
+		t.Errorf("wrong stats %+v, learned %d", stats, learned)
+	}
+}
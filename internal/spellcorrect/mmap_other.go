@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package spellcorrect
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile - reads file into memory on platforms without mmap support
+func mmapFile(f *os.File) (*mapping, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return &mapping{data: data}, nil
+}
@@ -0,0 +1,37 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package spellcorrect
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// mmapFile - maps file read-only into memory. Mapping is shared, so the file
+// must not be truncated or rewritten while it is mapped, models are replaced
+// by rename
+func mmapFile(f *os.File) (*mapping, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return &mapping{}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, syscall.EFBIG
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: err}
+	}
+	m := &mapping{data: data}
+	runtime.SetFinalizer(m, func(m *mapping) {
+		syscall.Munmap(m.data)
+	})
+	return m, nil
+}
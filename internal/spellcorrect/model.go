@@ -0,0 +1,534 @@
+package spellcorrect
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eskriett/spell"
+)
+
+// Model file layout:
+//
+//	magic "SPELLERM", format version uint32 big-endian,
+//	gzip stream of gob(ModelInfo), gob(modelDict), gob(Frequencies)
+//	and sha256 of the preceding uncompressed bytes.
+//
+// modelDict keeps error model since it was added, models without it
+// are read with empty error model and older versions ignore it.
+//
+// Models of version 2 keep n-grams model in flat format, so it is memory
+// mapped on load instead of decoding:
+//
+//	magic "SPELLERM", format version uint32 big-endian, 4 zero bytes,
+//	offset of flat n-grams model uint64 big-endian,
+//	gzip stream of gob(ModelInfo), gob(modelDict) and sha256 of them,
+//	zero padding to the offset, flat n-grams model and sha256 of it.
+//
+// Files starting with gzip header are models of version 0 without header,
+// they have only n-grams model.
+const (
+	modelMagic          = "SPELLERM"
+	modelVersion        = 1
+	modelVersionFlat    = 2
+	flatModelHeaderSize = 24
+)
+
+var (
+	// ErrModelFormat - file is not a speller model
+	ErrModelFormat = errors.New("not a speller model")
+	// ErrModelVersion - model format version is not supported
+	ErrModelVersion = errors.New("unsupported model format version")
+	// ErrModelTruncated - model file ends unexpectedly
+	ErrModelTruncated = errors.New("model is truncated")
+	// ErrModelChecksum - model content doesn't match its checksum
+	ErrModelChecksum = errors.New("model checksum mismatch")
+)
+
+// ModelInfo - metadata stored in the model header
+type ModelInfo struct {
+	Version       int       // format version of the model file
+	CreatedAt     time.Time // time the model was saved
+	Corpus        string    // description of the training corpus
+	NgramOrder    int       // max order of n-grams
+	MinWordLength int       // shorter words were ignored in n-grams training
+	MinWordFreq   int       // rarer words were ignored in training
+	Words         int       // amount of words in spell dictionary
+	ErrorPairs    int       // amount of pairs error model was trained on
+}
+
+// modelDict - spell dictionary and error model stored in the model file
+// before n-grams model
+type modelDict struct {
+	Words  map[string]uint64
+	Errors *errorModel
+}
+
+// SetCorpus - sets description of the training corpus saved in the model header
+func (o *SpellCorrector) SetCorpus(description string) {
+	o.corpus = description
+}
+
+// SetQuantizationBits - sets bits of probs quantization of models saved by
+// SaveFlatModel: 8, 16 or 0 to store probs without quantization
+func (o *SpellCorrector) SetQuantizationBits(bits int) {
+	o.quantizationBits = bits
+}
+
+// ModelInfo - returns metadata of the loaded or trained model
+func (o *SpellCorrector) ModelInfo() ModelInfo {
+	o.learnMu.Lock()
+	defer o.learnMu.Unlock()
+
+	if o.info.Version != 0 {
+		return o.info
+	}
+
+	info := ModelInfo{
+		Corpus:     o.corpus,
+		NgramOrder: o.frequencies.NgramOrder(),
+		Words:      len(o.dict),
+	}
+	if o.channel != nil {
+		info.ErrorPairs = o.channel.Pairs
+	}
+	info.MinWordLength, info.MinWordFreq = o.frequencies.TrainParams()
+	return info
+}
+
+// SaveModel - saves trained speller model with header and spell dictionary,
+// existing file is atomically replaced
+func (o *SpellCorrector) SaveModel(filename string) error {
+	return saveFile(filename, o.SaveModelTo)
+}
+
+// SaveModelTo - writes model to w, the same as SaveModel writes to file.
+// Model with flat n-grams model is written in flat format
+func (o *SpellCorrector) SaveModelTo(w io.Writer) error {
+	if _, ok := o.frequencies.(*FlatFrequencies); ok {
+		return o.SaveFlatModelTo(w)
+	}
+
+	if err := writeModelHeader(w, modelVersion); err != nil {
+		return err
+	}
+	return o.writeModelBody(w, modelVersion, true)
+}
+
+// SaveFlatModel - saves model with n-grams model in flat format, which is
+// memory mapped by LoadModel instead of decoding. Existing file is atomically
+// replaced, so models mapped from it stay intact
+func (o *SpellCorrector) SaveFlatModel(filename string) error {
+	return saveFile(filename, o.SaveFlatModelTo)
+}
+
+// SaveFlatModelTo - writes model with n-grams model in flat format to w
+func (o *SpellCorrector) SaveFlatModelTo(w io.Writer) error {
+	fw, ok := o.frequencies.(flatWriter)
+	if !ok {
+		return fmt.Errorf("n-grams model %T can't be written in flat format", o.frequencies)
+	}
+
+	var body bytes.Buffer
+	if err := o.writeModelBody(&body, modelVersionFlat, false); err != nil {
+		return err
+	}
+	offset := (flatModelHeaderSize + body.Len() + 7) &^ 7
+
+	if err := writeModelHeader(w, modelVersionFlat); err != nil {
+		return err
+	}
+	var header [12]byte
+	binary.BigEndian.PutUint64(header[4:], uint64(offset))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	padding := make([]byte, offset-flatModelHeaderSize-body.Len())
+	if _, err := w.Write(append(body.Bytes(), padding...)); err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if err := fw.WriteFlat(io.MultiWriter(w, h), o.quantizationBits); err != nil {
+		return err
+	}
+	_, err := w.Write(h.Sum(nil))
+	return err
+}
+
+// saveFile - writes file by write into a temporary file in the same
+// directory and renames it to filename, so readers and memory mapped
+// models never see partially written or truncated file
+func saveFile(filename string, write func(io.Writer) error) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// flatWriter - n-grams model which can be written in flat format
+type flatWriter interface {
+	WriteFlat(w io.Writer, bits int) error
+}
+
+// writeModelHeader - writes magic and format version
+func writeModelHeader(w io.Writer, version uint32) error {
+	var v [4]byte
+	binary.BigEndian.PutUint32(v[:], version)
+	if _, err := io.WriteString(w, modelMagic); err != nil {
+		return err
+	}
+	_, err := w.Write(v[:])
+	return err
+}
+
+// writeModelBody - writes gzipped info, dictionary, n-grams model if
+// withFreq and checksum
+func (o *SpellCorrector) writeModelBody(w io.Writer, version int, withFreq bool) error {
+	gz := gzip.NewWriter(w)
+	h := sha256.New()
+	hw := io.MultiWriter(gz, h)
+
+	info := o.ModelInfo()
+	info.Version = version
+	info.CreatedAt = time.Now().UTC()
+
+	enc := gob.NewEncoder(hw)
+	if err := enc.Encode(info); err != nil {
+		return err
+	}
+
+	o.learnMu.Lock()
+	err := enc.Encode(modelDict{Words: o.dict, Errors: o.channel})
+	o.learnMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if withFreq {
+		if err := o.frequencies.EncodeModel(hw); err != nil {
+			return err
+		}
+	}
+	if _, err := gz.Write(h.Sum(nil)); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// LoadModel - loades trained speller model from file. Model is applied only
+// after it is fully read and its checksum is verified, n-grams model of flat
+// model is memory mapped and verified only if SetVerifyModel enables it. Models of version 0 saved without dictionary are
+// loaded too, HasDict reports false for them
+func (o *SpellCorrector) LoadModel(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return o.loadModel(f, filename)
+}
+
+// LoadModelFS - loades trained speller model from file of fsys,
+// e.g. embedded with go:embed
+func (o *SpellCorrector) LoadModelFS(fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return o.LoadModelFrom(f)
+}
+
+// LoadModelFrom - loades model written by SaveModelTo or SaveModel from r,
+// n-grams model of flat model is read into memory
+func (o *SpellCorrector) LoadModelFrom(r io.Reader) error {
+	return o.loadModel(r, "")
+}
+
+// CopyModelTo - loads model of o into sc, they share nothing afterwards,
+// so sc can be changed while o serves corrections
+func (o *SpellCorrector) CopyModelTo(sc *SpellCorrector) error {
+	var buf bytes.Buffer
+	if err := o.SaveModelTo(&buf); err != nil {
+		return err
+	}
+	return sc.LoadModelFrom(&buf)
+}
+
+// loadModel - loades model from in, flat n-grams model is memory mapped
+// from filename if it is not empty
+func (o *SpellCorrector) loadModel(in io.Reader, filename string) error {
+	r := bufio.NewReader(in)
+	magic, err := r.Peek(len(modelMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if string(magic) != modelMagic {
+		if bytes.HasPrefix(magic, []byte{0x1f, 0x8b}) {
+			return o.loadModelV0(r)
+		}
+		return ErrModelFormat
+	}
+	r.Discard(len(modelMagic))
+
+	var version [4]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return truncated(err)
+	}
+	switch v := binary.BigEndian.Uint32(version[:]); v {
+	case modelVersion:
+		info, dict, freq, err := o.readModelBody(r, true)
+		if err != nil {
+			return err
+		}
+		o.apply(info, dict, freq)
+		return nil
+	case modelVersionFlat:
+		return o.readFlatModel(r, filename)
+	default:
+		return fmt.Errorf("%w %d, supported %d and %d", ErrModelVersion, v, modelVersion, modelVersionFlat)
+	}
+}
+
+// readFlatModel - reads model of version 2 after the version, checksum of
+// flat n-grams model is verified only if it is enabled by SetVerifyModel
+func (o *SpellCorrector) readFlatModel(r io.Reader, filename string) error {
+	if o.smoothing == Katz || o.smoothing == KneserNey {
+		return fmt.Errorf("%w, got %s", ErrFlatSmoothing, o.smoothing)
+	}
+
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return truncated(err)
+	}
+	offset := int64(binary.BigEndian.Uint64(header[4:]))
+	if offset < flatModelHeaderSize || offset%8 != 0 {
+		return fmt.Errorf("%w: wrong flat n-grams model offset %d", ErrModelFormat, offset)
+	}
+
+	body := io.LimitReader(r, offset-flatModelHeaderSize)
+	info, dict, _, err := o.readModelBody(body, false)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return err
+	}
+
+	var freq *flatModel
+	if filename != "" {
+		freq, err = openFlatModel(filename, offset, o.flatModelSum())
+	} else {
+		freq, err = readFlatModel(r, o.flatModelSum())
+	}
+	if err != nil {
+		return truncated(err)
+	}
+
+	o.apply(info, dict, &FlatFrequencies{flatModel: freq})
+	return nil
+}
+
+// readModelBody - reads gzipped info, dictionary, n-grams model if withFreq
+// and verifies checksum
+func (o *SpellCorrector) readModelBody(r io.Reader, withFreq bool) (ModelInfo, modelDict, FrequencyContainer, error) {
+	var (
+		info ModelInfo
+		dict modelDict
+	)
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return info, dict, nil, truncated(err)
+	}
+	defer gz.Close()
+	if !withFreq {
+		// flat model body is followed by padding
+		gz.Multistream(false)
+	}
+
+	hr := &hashReader{r: bufio.NewReader(gz), h: sha256.New()}
+	dec := gob.NewDecoder(hr)
+
+	if err := dec.Decode(&info); err != nil {
+		return info, dict, nil, truncated(err)
+	}
+	if err := dec.Decode(&dict); err != nil {
+		return info, dict, nil, truncated(err)
+	}
+	var freq *Frequencies
+	if withFreq {
+		freq = NewFrequencies(info.MinWordLength, info.MinWordFreq, info.NgramOrder)
+		if err := freq.DecodeModel(hr); err != nil {
+			return info, dict, nil, truncated(err)
+		}
+	}
+
+	sum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hr.r, sum); err != nil {
+		return info, dict, nil, truncated(err)
+	}
+	if !bytes.Equal(sum, hr.h.Sum(nil)) {
+		return info, dict, nil, ErrModelChecksum
+	}
+	// reading till the end verifies gzip checksum
+	if _, err := hr.r.ReadByte(); err != io.EOF {
+		if err == nil {
+			return info, dict, nil, fmt.Errorf("%w: unexpected data after checksum", ErrModelFormat)
+		}
+		return info, dict, nil, truncated(err)
+	}
+
+	// nil *Frequencies must not be returned as non-nil FrequencyContainer
+	if freq == nil {
+		return info, dict, nil, nil
+	}
+	return info, dict, freq, nil
+}
+
+// SetVerifyModel - enables verification of checksum of flat n-grams model
+// by LoadModel, it reads the whole model, so mapped model is no longer
+// loaded instantly. Layout of flat n-grams model is checked anyway
+func (o *SpellCorrector) SetVerifyModel(enabled bool) {
+	o.verifyModel = enabled
+}
+
+// flatModelSum - returns trailer of flat n-grams model data which verifies
+// sha256 following the data if verification is enabled or skips it
+func (o *SpellCorrector) flatModelSum() func([]byte) ([]byte, error) {
+	if o.verifyModel {
+		return verifyFlatModel
+	}
+	return skipFlatModelSum
+}
+
+// skipFlatModelSum - returns flat n-grams model data without sha256 which
+// follows it
+func skipFlatModelSum(data []byte) ([]byte, error) {
+	if len(data) < sha256.Size {
+		return nil, ErrModelTruncated
+	}
+	return data[:len(data)-sha256.Size], nil
+}
+
+// verifyFlatModel - returns flat n-grams model data without sha256 which
+// follows it, ErrModelChecksum if data doesn't match it
+func verifyFlatModel(data []byte) ([]byte, error) {
+	if len(data) < sha256.Size {
+		return nil, ErrModelTruncated
+	}
+	n := len(data) - sha256.Size
+	if sum := sha256.Sum256(data[:n]); !bytes.Equal(sum[:], data[n:]) {
+		return nil, ErrModelChecksum
+	}
+	return data[:n], nil
+}
+
+// apply - replaces spell dictionary, n-grams model and info with loaded ones
+func (o *SpellCorrector) apply(info ModelInfo, dict modelDict, freq FrequencyContainer) {
+	sp := spell.New()
+	sp.MaxEditDistance = uint32(o.params.MaxEditDistance)
+	words := make(map[string]uint64, len(dict.Words))
+	for word, freq := range dict.Words {
+		sp.AddEntry(spell.Entry{Frequency: freq, Word: word})
+		words[word] = freq
+	}
+
+	if s, ok := freq.(smoother); ok {
+		s.SetSmoothing(o.smoothing)
+	}
+	if t, ok := freq.(functionWordsTrainer); ok {
+		t.SetFunctionWords(o.functionWordList)
+	}
+
+	o.learnMu.Lock()
+	o.spell = sp
+	o.dict = words
+	o.channel = dict.Errors
+	o.frequencies = freq
+	o.info = info
+	o.learnMu.Unlock()
+}
+
+// loadModelV0 - loads model without header which has only n-grams model,
+// spell dictionary is loaded separately
+func (o *SpellCorrector) loadModelV0(in io.Reader) error {
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	return truncated(o.frequencies.DecodeModel(gz))
+}
+
+// HasDict - reports whether spell dictionary is loaded
+func (o *SpellCorrector) HasDict() bool {
+	o.learnMu.Lock()
+	defer o.learnMu.Unlock()
+
+	return len(o.dict) != 0
+}
+
+// truncated - marks unexpected end of file errors with ErrModelTruncated
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %v", ErrModelTruncated, err)
+	}
+	return err
+}
+
+// hashReader - hashes read bytes, implements io.ByteReader,
+// so gob decoders don't read ahead
+type hashReader struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (o *hashReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	o.h.Write(p[:n])
+	return n, err
+}
+
+func (o *hashReader) ReadByte() (byte, error) {
+	b, err := o.r.ReadByte()
+	if err == nil {
+		o.h.Write([]byte{b})
+	}
+	return b, err
+}
@@ -0,0 +1,142 @@
+package spellcorrect
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func saveTestModel(t *testing.T) (*SpellCorrector, string) {
+	trainwords := "golang 100\ngoland 1\npython 50"
+	traindata := `golang python C erlang golang java java golang goland`
+
+	sc := getSpellCorrector()
+	if err := sc.Train(strings.NewReader(traindata), strings.NewReader(trainwords)); err != nil {
+		t.Fatal(err)
+	}
+	sc.SetCorpus("golang corpus")
+
+	filename := filepath.Join(t.TempDir(), "model.gz")
+	if err := sc.SaveModel(filename); err != nil {
+		t.Fatal(err)
+	}
+	return sc, filename
+}
+
+func TestModelInfo(t *testing.T) {
+	_, filename := saveTestModel(t)
+
+	loaded := getSpellCorrector()
+	if err := loaded.LoadModel(filename); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	info := loaded.ModelInfo()
+	if info.Version != modelVersion || info.Corpus != "golang corpus" || info.NgramOrder != 3 || info.Words != 3 {
+		t.Errorf("wrong model info %+v", info)
+		return
+	}
+	if info.CreatedAt.IsZero() {
+		t.Errorf("creation time is not set")
+	}
+}
+
+func TestLoadModelRejectsBrokenFiles(t *testing.T) {
+	_, filename := saveTestModel(t)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// payload with changed corpus description
+	gz, err := gzip.NewReader(bytes.NewReader(data[len(modelMagic)+4:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload = bytes.Replace(payload, []byte("golang corpus"), []byte("python corpus"), 1)
+	var changed bytes.Buffer
+	changed.Write(data[:len(modelMagic)+4])
+	w := gzip.NewWriter(&changed)
+	w.Write(payload)
+	w.Close()
+
+	version := append([]byte{}, data...)
+	version[len(modelMagic)+3] = 99
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"truncated", data[:len(data)/2], ErrModelTruncated},
+		{"truncated header", data[:len(modelMagic)+2], ErrModelTruncated},
+		{"checksum", changed.Bytes(), ErrModelChecksum},
+		{"version", version, ErrModelVersion},
+		{"format", []byte("word 1\nother 2\n"), ErrModelFormat},
+	}
+
+	for _, test := range tests {
+		broken := filepath.Join(t.TempDir(), test.name)
+		if err := os.WriteFile(broken, test.data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		sc := getSpellCorrector()
+		err := sc.LoadModel(broken)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, err)
+			continue
+		}
+		if sc.HasDict() {
+			t.Errorf("%s: broken model is applied", test.name)
+		}
+	}
+}
+
+func TestLoadModelFromReaderAndFS(t *testing.T) {
+	sc, filename := saveTestModel(t)
+
+	var buf bytes.Buffer
+	if err := sc.SaveModelTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := getSpellCorrector()
+	if err := loaded.LoadModelFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if suggestions := loaded.Suggestions("pythn", 1); suggestions[0].Tokens[0] != "python" {
+		t.Errorf("wrong suggestion %v", suggestions[0].Tokens)
+	}
+
+	fsys := os.DirFS(filepath.Dir(filename))
+	fromFS := getSpellCorrector()
+	if err := fromFS.LoadModelFS(fsys, filepath.Base(filename)); err != nil {
+		t.Fatal(err)
+	}
+	if info := fromFS.ModelInfo(); info.Corpus != "golang corpus" {
+		t.Errorf("wrong model info %+v", info)
+	}
+
+	// model of version 0 without dictionary is read from the stream once
+	buf.Reset()
+	if err := sc.frequencies.SaveModelTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	legacy := getSpellCorrector()
+	if err := legacy.LoadModelFrom(io.MultiReader(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if prob := legacy.frequencies.Get([]string{"golang"}); prob != sc.frequencies.Get([]string{"golang"}) {
+		t.Errorf("wrong n-grams model prob %f", prob)
+	}
+}
@@ -0,0 +1,146 @@
+package spellcorrect
+
+import (
+	"fmt"
+	"math"
+)
+
+// PruneParams - parameters of n-grams model pruning
+type PruneParams struct {
+	// MinCounts - n-grams of order i+1 met less than MinCounts[i] times are
+	// removed together with their continuations, missing orders are not pruned
+	MinCounts []int
+	// Threshold - n-grams whose removal changes relative entropy of the model
+	// by less than Threshold in either direction are removed, 0 disables it
+	Threshold float64
+}
+
+// PruneStats - amount of n-grams of each order before and after pruning
+type PruneStats struct {
+	Before []int
+	After  []int
+}
+
+// Total - returns amount of n-grams of all orders before and after pruning
+func (o PruneStats) Total() (before, after int) {
+	for i := range o.Before {
+		before += o.Before[i]
+		after += o.After[i]
+	}
+	return before, after
+}
+
+// pruner - n-grams model which can be pruned
+type pruner interface {
+	Prune(params PruneParams) PruneStats
+}
+
+// Prune - prunes n-grams model in place, returns error if it can't be pruned
+func (o *SpellCorrector) Prune(params PruneParams) (PruneStats, error) {
+	if err := o.CanPrune(); err != nil {
+		return PruneStats{}, err
+	}
+	return o.frequencies.(pruner).Prune(params), nil
+}
+
+// CanPrune - returns error if n-grams model can't be pruned
+func (o *SpellCorrector) CanPrune() error {
+	if _, ok := o.frequencies.(pruner); !ok {
+		return fmt.Errorf("n-grams model %T can't be pruned", o.frequencies)
+	}
+	return nil
+}
+
+// Prune - removes n-grams by count thresholds and then by relative entropy.
+// Removed n-gram is scored by its lower order n-gram, so relative entropy
+// of n-gram hw is P(hw) * |log P(w|h) - log P(w|h')|, where h' is h without
+// the first word. N-grams which context makes less probable than the lower
+// order one are as informative as ones it makes more probable. Only n-grams
+// without continuations are removed by relative entropy, starting from the
+// highest order. Unlike Stolcke pruning, probs of kept n-grams are not kept:
+// smoothing statistics and backoff weights are estimated again from the kept
+// counts, so smoothed probs of words after each context sum to 1
+func (o *Frequencies) Prune(params PruneParams) PruneStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	trie := o.Trie
+	nodes := trie.wire()
+	size := trie.size()
+
+	// depth and amount of kept children of each node, 0 is the root
+	depth := make([]uint8, size+1)
+	children := make([]int, size+1)
+	keep := make([]bool, size+1)
+	keep[0] = true
+
+	stats := PruneStats{
+		Before: make([]int, o.Order),
+		After:  make([]int, o.Order),
+	}
+	for node := 1; node <= size; node++ {
+		parent := nodes.Parents[node-1]
+		depth[node] = depth[parent] + 1
+		order := int(depth[node])
+		stats.Before[order-1]++
+
+		keep[node] = keep[parent]
+		if order <= len(params.MinCounts) && trie.freq(uint32(node)) < params.MinCounts[order-1] {
+			keep[node] = false
+		}
+		if keep[node] {
+			children[parent]++
+		}
+	}
+
+	if params.Threshold > 0 {
+		key := make(ngram, o.Order)
+		for order := o.Order; order > 1; order-- {
+			for node := 1; node <= size; node++ {
+				if int(depth[node]) != order || !keep[node] || children[node] != 0 {
+					continue
+				}
+
+				// words of n-gram from the last one
+				current := uint32(node)
+				for i := order - 1; i >= 0; i-- {
+					key[i] = nodes.Hashes[current-1]
+					current = nodes.Parents[current-1]
+				}
+				prob := trie.prob(key[:order])
+				joint := float64(trie.freq(uint32(node))) / float64(trie.rootFreq)
+				lower := trie.prob(key[1:order])
+
+				entropy := math.Inf(1)
+				if lower > 0 {
+					entropy = joint * math.Abs(math.Log(prob)-math.Log(lower))
+				}
+				if entropy < params.Threshold {
+					keep[node] = false
+					children[nodes.Parents[node-1]]--
+				}
+			}
+		}
+	}
+
+	// nodes are rebuilt in the same order, so parents precede children
+	pruned := newWordTrie(trie.rootFreq)
+	ids := make([]uint32, size+1)
+	for node := 1; node <= size; node++ {
+		hash := nodes.Hashes[node-1]
+		if !keep[node] {
+			if depth[node] == 1 {
+				delete(o.UniGramProbs, hash)
+			}
+			continue
+		}
+		ids[node] = uint32(len(pruned.freqs))
+		pruned.children[trieKey{ids[nodes.Parents[node-1]], hash}] = ids[node]
+		pruned.freqs = append(pruned.freqs, nodes.Freqs[node-1])
+		stats.After[depth[node]-1]++
+	}
+	o.Trie = pruned
+	o.updateStats()
+
+	return stats
+}
@@ -0,0 +1,101 @@
+package spellcorrect
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func trainPruneFrequencies(t *testing.T) *Frequencies {
+	text := strings.Repeat("i program go\n", 3) + "i code and i cook code\n"
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
+	if err := freq.TrainNgrams(strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	return freq
+}
+
+func TestPruneMinCounts(t *testing.T) {
+	freq := trainPruneFrequencies(t)
+	before := freq.Get([]string{"i", "program", "go"})
+
+	stats := freq.Prune(PruneParams{MinCounts: []int{1, 1, 2}})
+	if stats.Before[2] != 5 || stats.After[2] != 1 {
+		t.Errorf("wrong amount of trigrams %+v", stats)
+	}
+	if stats.Before[0] != stats.After[0] || stats.Before[1] != stats.After[1] {
+		t.Errorf("lower orders are pruned %+v", stats)
+	}
+	if prob := freq.Get([]string{"i", "program", "go"}); prob != before {
+		t.Errorf("prob of kept trigram changed %f, expected %f", prob, before)
+	}
+	if prob := freq.Get([]string{"i", "cook", "code"}); prob != 0 {
+		t.Errorf("singleton trigram is not pruned")
+	}
+
+	// continuations of pruned n-grams are pruned too
+	stats = freq.Prune(PruneParams{MinCounts: []int{2}})
+	if freq.Get([]string{"cook"}) != 0 || freq.Get([]string{"cook", "code"}) != 0 {
+		t.Errorf("rare unigram or its continuation is not pruned")
+	}
+	if freq.Get([]string{"i", "program"}) == 0 {
+		t.Errorf("frequent bigram is pruned")
+	}
+	if before, after := stats.Total(); after >= before {
+		t.Errorf("nothing is pruned %+v", stats)
+	}
+}
+
+func TestPruneRelativeEntropy(t *testing.T) {
+	freq := trainPruneFrequencies(t)
+
+	// "program go" is always preceded by "i", so the trigram adds nothing
+	stats := freq.Prune(PruneParams{Threshold: 1e-9})
+	if freq.Get([]string{"i", "program", "go"}) != 0 {
+		t.Errorf("trigram predicted by bigram is not pruned")
+	}
+	if freq.Get([]string{"i", "program"}) == 0 || freq.Get([]string{"program", "go"}) == 0 {
+		t.Errorf("informative bigram is pruned")
+	}
+	if stats.After[0] != stats.Before[0] {
+		t.Errorf("unigrams are pruned %+v", stats)
+	}
+
+	// context makes "cloth" less probable after "red cotton" than after "cotton"
+	text := "red cotton cloth\n" + strings.Repeat("red cotton sheet\n", 3) + strings.Repeat("blue cotton cloth\n", 4)
+	cloth := NewFrequencies(0, 0, DefaultNgramOrder)
+	if err := cloth.TrainNgrams(strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	cloth.Prune(PruneParams{Threshold: 1e-9})
+	if prob := cloth.Get([]string{"red", "cotton", "cloth"}); prob != 0.25 {
+		t.Errorf("trigram less probable than bigram is pruned, prob %f", prob)
+	}
+
+	stats = freq.Prune(PruneParams{Threshold: 1})
+	if stats.After[1] != 0 || stats.After[2] != 0 {
+		t.Errorf("not all n-grams are pruned with big threshold %+v", stats)
+	}
+}
+
+func TestPruneSmoothingNormalized(t *testing.T) {
+	vocabulary := []string{"i", "program", "go", "code", "and", "cook", "unknown"}
+	contexts := [][]string{nil, {"i"}, {"go"}, {"code"}, {"i", "program"}, {"i", "code"}, {"and", "i"}}
+
+	for _, params := range []PruneParams{{MinCounts: []int{1, 2, 2}}, {Threshold: 1e-9}, {Threshold: 0.05}} {
+		for _, smoothing := range []Smoothing{Katz, KneserNey} {
+			freq := trainSmoothingFrequencies(t, smoothing)
+			freq.Prune(params)
+			for _, context := range contexts {
+				var sum float64
+				for _, word := range vocabulary {
+					tokens := append(append([]string{}, context...), word)
+					sum += math.Exp(freq.LogProb(tokens))
+				}
+				if math.Abs(sum-1) > 1e-9 {
+					t.Errorf("%v pruned by %+v: probs of words after %q sum to %f", smoothing, params, context, sum)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package spellcorrect
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"unsafe"
+)
+
+// codes - quantized probs of n-grams: indexes of log-probs in codebook,
+// code 0 is reserved for zero prob
+type codes struct {
+	codebook []float64
+	codes8   []uint8
+	codes16  []uint16
+}
+
+// newCodes - returns codes of given bits stored in raw without copying
+// on little-endian platforms
+func newCodes(bits int, codebook []float64, raw []byte) codes {
+	c := codes{codebook: codebook}
+	if bits == 8 {
+		c.codes8 = raw
+		return c
+	}
+	c.codes16 = uint16s(raw)
+	return c
+}
+
+// prob - returns prob of n-gram j
+func (o *codes) prob(j int) float64 {
+	var code int
+	if o.codes8 != nil {
+		code = int(o.codes8[j])
+	} else {
+		code = int(o.codes16[j])
+	}
+	if code >= len(o.codebook) {
+		return 0.0
+	}
+	return math.Exp(o.codebook[code])
+}
+
+// quantize - returns codebook of log-probs and codes of probs. If there are
+// less distinct probs than 1<<bits, they are stored exactly, otherwise range
+// of log-probs is split into bins of equal width and codebook holds bins'
+// mean log-probs, so error of restored log-prob is at most the bin width
+func quantize(probs []float64, bits int) ([]float64, []uint16, error) {
+	if bits != 8 && bits != 16 {
+		return nil, nil, fmt.Errorf("quantization bits must be 8 or 16, got %d", bits)
+	}
+	bins := 1<<bits - 1
+
+	if codebook, codes, ok := quantizeExact(probs, bins); ok {
+		return codebook, codes, nil
+	}
+
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, prob := range probs {
+		if prob > 0 {
+			min = math.Min(min, math.Log(prob))
+			max = math.Max(max, math.Log(prob))
+		}
+	}
+	width := (max - min) / float64(bins)
+
+	// code 0 means zero prob
+	out := make([]uint16, len(probs))
+	sums := make([]float64, bins+1)
+	counts := make([]int, bins+1)
+	last := 0
+	for i, prob := range probs {
+		if prob <= 0 {
+			continue
+		}
+		v := math.Log(prob)
+		code := 1
+		if width > 0 {
+			code += int(math.Min((v-min)/width, float64(bins-1)))
+		}
+		out[i] = uint16(code)
+		sums[code] += v
+		counts[code]++
+		if code > last {
+			last = code
+		}
+	}
+
+	codebook := make([]float64, last+1)
+	codebook[0] = math.Inf(-1)
+	for code := 1; code <= last; code++ {
+		if counts[code] != 0 {
+			codebook[code] = sums[code] / float64(counts[code])
+		}
+	}
+	return codebook, out, nil
+}
+
+// quantizeExact - returns codebook of distinct log-probs if there are
+// at most bins of them
+func quantizeExact(probs []float64, bins int) ([]float64, []uint16, bool) {
+	index := make(map[float64]uint16)
+	for _, prob := range probs {
+		if prob <= 0 {
+			continue
+		}
+		if _, ok := index[prob]; !ok {
+			if len(index) == bins {
+				return nil, nil, false
+			}
+			index[prob] = 0
+		}
+	}
+
+	distinct := make([]float64, 0, len(index))
+	for prob := range index {
+		distinct = append(distinct, prob)
+	}
+	sort.Float64s(distinct)
+
+	codebook := make([]float64, len(distinct)+1)
+	codebook[0] = math.Inf(-1)
+	for i, prob := range distinct {
+		codebook[i+1] = math.Log(prob)
+		index[prob] = uint16(i + 1)
+	}
+
+	out := make([]uint16, len(probs))
+	for i, prob := range probs {
+		if prob > 0 {
+			out[i] = index[prob]
+		}
+	}
+	return codebook, out, true
+}
+
+// uint16s - returns data as []uint16, data must be 2 bytes aligned
+func uint16s(data []byte) []uint16 {
+	if len(data) == 0 {
+		return nil
+	}
+	if littleEndian && uintptr(unsafe.Pointer(&data[0]))%2 == 0 {
+		return unsafe.Slice((*uint16)(unsafe.Pointer(&data[0])), len(data)/2)
+	}
+	out := make([]uint16, len(data)/2)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return out
+}
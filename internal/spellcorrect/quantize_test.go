@@ -0,0 +1,88 @@
+package spellcorrect
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestQuantize(t *testing.T) {
+	probs := []float64{0.5, 0.25, 0, 0.5, 0.125}
+	codebook, codes, err := quantize(probs, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// few distinct values are restored exactly
+	for i, prob := range probs {
+		if got := math.Exp(codebook[codes[i]]); math.Abs(got-prob) > 1e-12 {
+			t.Errorf("wrong restored prob %f, expected %f", got, prob)
+		}
+	}
+	if codes[2] != 0 {
+		t.Errorf("zero prob has code %d", codes[2])
+	}
+
+	probs = make([]float64, 100000)
+	for i := range probs {
+		probs[i] = float64(i+1) / float64(len(probs))
+	}
+	for _, bits := range []int{8, 16} {
+		codebook, codes, err := quantize(probs, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var maxErr float64
+		for i, prob := range probs {
+			maxErr = math.Max(maxErr, math.Abs(codebook[codes[i]]-math.Log(prob)))
+		}
+		if bits == 8 && maxErr > 0.1 || bits == 16 && maxErr > 0.01 {
+			t.Errorf("too big log-prob error %f of %d bits quantization", maxErr, bits)
+		}
+	}
+
+	if _, _, err := quantize(probs, 4); err == nil {
+		t.Errorf("expected error for 4 bits")
+	}
+}
+
+func TestQuantizedFlatFrequencies(t *testing.T) {
+	tokens := strings.Repeat("i program go i code and i cook code\n", 3)
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
+	if err := freq.TrainNgrams(strings.NewReader(tokens)); err != nil {
+		t.Fatal(err)
+	}
+
+	var full bytes.Buffer
+	if err := freq.WriteFlat(&full, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, bits := range []int{8, 16} {
+		var buf bytes.Buffer
+		if err := freq.WriteFlat(&buf, bits); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() >= full.Len() {
+			t.Errorf("quantized model size %d is not less than %d", buf.Len(), full.Len())
+		}
+
+		flat, err := ReadFlatFrequencies(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flat.QuantizationBits() != bits {
+			t.Errorf("wrong quantization bits %d", flat.QuantizationBits())
+		}
+		words := strings.Fields(tokens)
+		for size := 1; size <= DefaultNgramOrder; size++ {
+			for _, gram := range TokenNgrams(words, size) {
+				if got, want := flat.Get(gram), freq.Get(gram); math.Abs(got-want) > 1e-9 {
+					t.Errorf("wrong prob of %v: %f, expected %f", gram, got, want)
+				}
+			}
+		}
+		if prob := flat.Get([]string{"python"}); prob != 0 {
+			t.Errorf("prob of missing unigram is %f", prob)
+		}
+	}
+}
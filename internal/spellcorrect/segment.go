@@ -0,0 +1,90 @@
+package spellcorrect
+
+import (
+	"math"
+
+	"github.com/eskriett/spell"
+)
+
+// segmentOrder - max order of n-grams scoring words of segmented text
+const segmentOrder = 2
+
+// Segment - splits text without spaces into the most probable words and
+// corrects their typos. Words are dictionary words within lookup edit
+// distance of the text parts scored by unigram and bigram log-probs, parts
+// without dictionary words are kept as unknown words which are penalized
+// by their length. Score is mean per word, text without letters and
+// numbers gives empty suggestion
+func (o *SpellCorrector) Segment(text string) Suggestion {
+	runes := []rune(Normalize(text))
+	if len(runes) == 0 {
+		return newSuggestions(1)[0]
+	}
+
+	order := segmentOrder
+	if n := o.frequencies.NgramOrder(); n < order {
+		order = n
+	}
+	maxLength := int(o.spell.GetLongestWord()) + o.params.EditDistance
+	if maxLength < 1 {
+		maxLength = 1
+	}
+
+	// beams[i] - partial segmentations of the first i runes
+	beams := make([][]*latticeNode, len(runes)+1)
+	beams[0] = []*latticeNode{nil}
+	buf := make([]string, order)
+	for i := range runes {
+		beam := prune(beams[i], o.params.BeamWidth)
+		for length := 1; length <= maxLength && i+length <= len(runes); length++ {
+			part := string(runes[i : i+length])
+			candidates, known := o.segmentCandidates(part)
+			for _, prev := range beam {
+				for _, c := range candidates {
+					node := o.extend(prev, c.word, i, order, buf)
+					beams[i+length] = append(beams[i+length], o.penalize(node, prev, c.cost))
+				}
+				if !known {
+					node := o.penalize(o.extend(prev, part, i, order, buf), prev, o.cost(part, part, 0))
+					node.score -= float64(length-1) * math.Ln10
+					beams[i+length] = append(beams[i+length], node)
+				}
+			}
+		}
+	}
+
+	best := prune(beams[len(runes)], 1)[0]
+	return Suggestion{
+		score:  best.score / float64(best.len),
+		Tokens: best.tokens(),
+	}
+}
+
+// segmentCandidates - returns up to MaxCandidates closest dictionary words
+// to the part of segmented text and whether the part is a word itself.
+// Parts shorter than min word length are only exact dictionary or
+// function words
+func (o *SpellCorrector) segmentCandidates(part string) ([]candidate, bool) {
+	if _, ok := o.functionWords[part]; ok {
+		return []candidate{{word: part, cost: o.cost(part, part, 0)}}, true
+	}
+	if len([]rune(part)) < o.params.MinWordLength {
+		if entry, _ := o.spell.GetEntry(part); entry != nil {
+			return []candidate{{word: part, cost: o.cost(part, part, 0)}}, true
+		}
+		return nil, false
+	}
+
+	suggestions, _ := o.spell.Lookup(part, spell.EditDistance(uint32(o.params.EditDistance)), spell.SuggestionLevel(spell.LevelClosest))
+	candidates := make([]candidate, 0, len(suggestions))
+	var known bool
+	for j := 0; j < len(suggestions) && j < o.params.MaxCandidates; j++ {
+		candidates = append(candidates, candidate{
+			word:     suggestions[j].Word,
+			distance: suggestions[j].Distance,
+			cost:     o.cost(part, suggestions[j].Word, float64(suggestions[j].Distance)),
+		})
+		known = known || suggestions[j].Distance == 0
+	}
+	return candidates, known
+}
@@ -0,0 +1,33 @@
+package spellcorrect
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSegment(t *testing.T) {
+	sc := trainDecoderCorrector(t)
+	for _, word := range strings.Fields("yellow table cloth for the kitchen red lamp bedroom") {
+		sc.addEntry(word, 10)
+	}
+
+	for text, expected := range map[string]string{
+		"yellowtableclothforthekitchen": "yellow table cloth for the kitchen",
+		"redtablelampforthebedrom":      "red table lamp for the bedroom",
+		"Kitchen!":                      "kitchen",
+		"xyzq":                          "xyzq",
+	} {
+		suggestion := sc.Segment(text)
+		if segmented := strings.Join(suggestion.Tokens, " "); segmented != expected {
+			t.Errorf("wrong segmentation of %q: %q, expected %q", text, segmented, expected)
+		}
+		if math.IsInf(suggestion.Score(), 0) {
+			t.Errorf("score of %q is %f", text, suggestion.Score())
+		}
+	}
+
+	if suggestion := sc.Segment("!"); suggestion.Tokens != nil {
+		t.Errorf("wrong segmentation of text without words %q", suggestion.Tokens)
+	}
+}
@@ -0,0 +1,474 @@
+package spellcorrect
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+)
+
+// Smoothing - scheme of n-grams probs smoothing used to score suggestions
+type Smoothing int
+
+const (
+	// SmoothingNone - suggestions are scored by raw n-grams probs and weights
+	// of unigrams, bigrams and trigrams as in previous versions
+	SmoothingNone Smoothing = iota
+	// StupidBackoff - relative frequency of the longest known n-gram, scaled
+	// by stupidBackoffFactor for each shortened context
+	StupidBackoff
+	// Katz - Good-Turing discounted probs with backoff to lower orders
+	Katz
+	// KneserNey - interpolated modified Kneser-Ney smoothing
+	KneserNey
+)
+
+// String - returns name of the smoothing used in config
+func (o Smoothing) String() string {
+	switch o {
+	case SmoothingNone:
+		return "none"
+	case StupidBackoff:
+		return "stupid_backoff"
+	case Katz:
+		return "katz"
+	case KneserNey:
+		return "kneser_ney"
+	}
+	return fmt.Sprintf("Smoothing(%d)", int(o))
+}
+
+const (
+	// stupidBackoffFactor - scale of the prob for each shortened context
+	stupidBackoffFactor = 0.4
+	// katzMaxCount - counts greater than it are not discounted by Katz smoothing
+	katzMaxCount = 5
+)
+
+// smoother - n-grams model which supports several smoothing schemes
+type smoother interface {
+	SetSmoothing(smoothing Smoothing)
+}
+
+// SetSmoothing - sets smoothing of probs returned by LogProb, statistics
+// of Katz and Kneser-Ney smoothing are computed at once
+func (o *Frequencies) SetSmoothing(smoothing Smoothing) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if smoothing == o.smoothing && o.stats != nil {
+		return
+	}
+	o.smoothing = smoothing
+	o.updateStats()
+}
+
+// updateStats - computes statistics of Katz and Kneser-Ney smoothing of the
+// trie when it is trained, loaded or pruned, so queries never compute them.
+// Must be called with mu held for writing
+func (o *Frequencies) updateStats() {
+	o.stats = nil
+	if o.smoothing == Katz || o.smoothing == KneserNey {
+		o.stats = newSmoothingStats(o.Trie, o.smoothing, o.Order)
+	}
+}
+
+// LogProb - returns smoothed log-prob of the last token given previous ones,
+// only the last Order-1 of them are used. Unknown words get prob of the
+// unknown word, so log-prob is finite for any tokens. Statistics of Katz and
+// Kneser-Ney smoothing are computed by training, loading and pruning, n-grams
+// learned in auto train mode do not update them
+func (o *Frequencies) LogProb(tokens []string) float64 {
+	if len(tokens) == 0 {
+		return 0.0
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if len(tokens) > o.Order {
+		tokens = tokens[len(tokens)-o.Order:]
+	}
+	key := make(ngram, len(tokens))
+	for i := range tokens {
+		key[i] = hashString(tokens[i])
+	}
+
+	var prob float64
+	switch o.smoothing {
+	case Katz:
+		prob = o.stats.katz(o.Trie, key)
+	case KneserNey:
+		prob = o.stats.kneserNey(o.Trie, key)
+	default:
+		prob = o.stupidBackoff(key)
+	}
+	return math.Log(prob)
+}
+
+// stupidBackoff - returns stupid backoff score of the last word of key,
+// unknown word gets one count of all words and the vocabulary
+func (o *Frequencies) stupidBackoff(key ngram) float64 {
+	scale := 1.0
+	for ; len(key) > 1; key = key[1:] {
+		if prob := o.Trie.prob(key); prob > 0 {
+			return scale * prob
+		}
+		scale *= stupidBackoffFactor
+	}
+	if prob := o.Trie.prob(key); prob > 0 {
+		return scale * prob
+	}
+	return scale / float64(o.Trie.rootFreq+len(o.UniGramProbs)+1)
+}
+
+// LogProb - returns stupid backoff log-prob of the last token given previous
+// ones, other smoothing schemes need counts which flat model may not have,
+// so flat models are not loaded with them
+func (o *FlatFrequencies) LogProb(tokens []string) float64 {
+	o.mu.RLock()
+	model := o.flatModel
+	o.mu.RUnlock()
+
+	prob := model.stupidBackoff(tokens)
+	runtime.KeepAlive(model)
+	return math.Log(prob)
+}
+
+func (o *flatModel) stupidBackoff(tokens []string) float64 {
+	if len(tokens) > len(o.levels) {
+		tokens = tokens[len(tokens)-len(o.levels):]
+	}
+	if len(tokens) == 0 {
+		return 1.0
+	}
+
+	scale := 1.0
+	for ; len(tokens) > 1; tokens = tokens[1:] {
+		if prob := o.get(tokens); prob > 0 {
+			return scale * prob
+		}
+		scale *= stupidBackoffFactor
+	}
+	if prob := o.get(tokens); prob > 0 {
+		return scale * prob
+	}
+	return scale / float64(o.total+uint64(len(o.levels[0].hashes))+1)
+}
+
+// smoothingStats - statistics of trie nodes needed by Katz and Kneser-Ney
+// smoothing, slices are indexed by node, 0 is the root
+type smoothingStats struct {
+	// order - max order of n-grams
+	order int
+	// totals - sum of counts of node children, continuation counts of them
+	// for Kneser-Ney if they are not of the highest order
+	totals []uint64
+	// discounts - discounts of counts of n-grams of each order
+	discounts [][]float64
+
+	// Kneser-Ney: continuation counts, amount of children with count 1, 2
+	// and 3 or more, size of the vocabulary
+	continuations []uint64
+	counts        [][3]uint32
+	vocabulary    int
+
+	// Katz: backoff weights and prob of unknown word
+	alphas  []float64
+	unknown float64
+}
+
+// newSmoothingStats - computes statistics of the trie of n-grams up to order
+// for smoothing
+func newSmoothingStats(trie *WordTrie, smoothing Smoothing, order int) *smoothingStats {
+	if smoothing == Katz {
+		return newKatzStats(trie, order)
+	}
+	return newKneserNeyStats(trie, order)
+}
+
+// newKneserNeyStats - computes continuation counts and modified Kneser-Ney
+// discounts, continuation count of n-gram is the amount of distinct words
+// preceding it
+func newKneserNeyStats(trie *WordTrie, order int) *smoothingStats {
+	nodes := trie.wire()
+	size := trie.size()
+	depth := nodes.depths()
+
+	stats := &smoothingStats{
+		order:         order,
+		discounts:     make([][]float64, order),
+		totals:        make([]uint64, size+1),
+		continuations: make([]uint64, size+1),
+		counts:        make([][3]uint32, size+1),
+	}
+
+	// occurrences of n-grams preceded by some word, the rest are at the
+	// beginning of sentences which counts as one more preceding word
+	preceded := make([]uint64, size+1)
+	key := make(ngram, order)
+	for node := 1; node <= size; node++ {
+		if depth[node] == 1 {
+			stats.vocabulary++
+		}
+		if depth[node] < 2 {
+			continue
+		}
+		suffix, _, ok := trie.find(nodes.key(uint32(node), key)[1:])
+		if ok {
+			stats.continuations[suffix]++
+			preceded[suffix] += uint64(trie.freq(uint32(node)))
+		}
+	}
+	for node := 1; node <= size; node++ {
+		if uint64(trie.freq(uint32(node))) > preceded[node] {
+			stats.continuations[node]++
+		}
+	}
+
+	countOfCounts := make([][4]float64, order)
+	for node := 1; node <= size; node++ {
+		count := stats.count(trie, uint32(node), int(depth[node]))
+		if count == 0 {
+			continue
+		}
+		if count <= 4 {
+			countOfCounts[depth[node]-1][count-1]++
+		}
+		parent := nodes.Parents[node-1]
+		stats.totals[parent] += count
+		stats.counts[parent][minInt(int(count), 3)-1]++
+	}
+	for order := range stats.discounts {
+		stats.discounts[order] = kneserNeyDiscounts(countOfCounts[order])
+	}
+
+	return stats
+}
+
+// kneserNeyDiscounts - returns discounts of counts 1, 2 and 3 or more from
+// amounts of n-grams with counts 1 to 4, D(c) = c - (c+1) Y n(c+1) / n(c),
+// where Y = n1 / (n1 + 2 n2). Half of the count is used if the estimate
+// would leave nothing of the count or can't be made
+func kneserNeyDiscounts(n [4]float64) []float64 {
+	y := n[0] / (n[0] + 2*n[1])
+	discounts := make([]float64, 3)
+	for i := range discounts {
+		c := float64(i + 1)
+		d := c - (c+1)*y*n[i+1]/n[i]
+		if n[i] == 0 || math.IsNaN(d) || d <= 0 || d >= c {
+			d = c / 2
+		}
+		discounts[i] = d
+	}
+	return discounts
+}
+
+// count - returns Kneser-Ney count of the node: count of n-grams of the
+// highest order and continuation count of others
+func (o *smoothingStats) count(trie *WordTrie, node uint32, order int) uint64 {
+	if order == o.order {
+		return uint64(trie.freq(node))
+	}
+	if int(node) < len(o.continuations) {
+		return o.continuations[node]
+	}
+	return 0
+}
+
+// kneserNey - returns interpolated modified Kneser-Ney prob of the last word
+// of key, orders are interpolated from the uniform distribution over the
+// vocabulary and unknown word up to the longest known context
+func (o *smoothingStats) kneserNey(trie *WordTrie, key ngram) float64 {
+	word := key[len(key)-1]
+	context := key[:len(key)-1]
+
+	prob := 1 / float64(o.vocabulary+1)
+	for n := 0; n <= len(context); n++ {
+		var node uint32
+		if n != 0 {
+			var ok bool
+			node, _, ok = trie.find(context[len(context)-n:])
+			if !ok {
+				break
+			}
+		}
+		if int(node) >= len(o.totals) || o.totals[node] == 0 {
+			break
+		}
+
+		var count uint64
+		if child, ok := trie.children[trieKey{node, word}]; ok {
+			count = o.count(trie, child, n+1)
+		}
+		discounts := o.discounts[n]
+		total := float64(o.totals[node])
+		gamma := 0.0
+		for i, d := range discounts {
+			gamma += d * float64(o.counts[node][i])
+		}
+		prob = math.Max(float64(count)-discount(discounts, count), 0)/total + gamma/total*prob
+	}
+	return prob
+}
+
+// discount - returns discount of the count, the last discount is used
+// for all greater counts
+func discount(discounts []float64, count uint64) float64 {
+	if count == 0 {
+		return 0
+	}
+	if int(count) > len(discounts) {
+		return discounts[len(discounts)-1]
+	}
+	return discounts[count-1]
+}
+
+// newKatzStats - computes Good-Turing discounts and backoff weights,
+// weights of shorter contexts are computed first as longer ones need them
+func newKatzStats(trie *WordTrie, order int) *smoothingStats {
+	nodes := trie.wire()
+	size := trie.size()
+	depth := nodes.depths()
+
+	stats := &smoothingStats{
+		order:     order,
+		discounts: make([][]float64, order),
+		totals:    make([]uint64, size+1),
+		alphas:    make([]float64, size+1),
+	}
+
+	countOfCounts := make([][katzMaxCount + 1]float64, order)
+	for node := 1; node <= size; node++ {
+		count := trie.freq(uint32(node))
+		if count >= 1 && count <= katzMaxCount+1 {
+			countOfCounts[depth[node]-1][count-1]++
+		}
+		stats.totals[nodes.Parents[node-1]] += uint64(count)
+	}
+	for order := range stats.discounts {
+		stats.discounts[order] = katzDiscounts(countOfCounts[order])
+	}
+
+	// unknown word gets the mass left by discounted unigrams
+	left := 1.0
+	for node := 1; node <= size; node++ {
+		if depth[node] == 1 {
+			left -= stats.discounted(trie, uint32(node), 0, 1)
+		}
+	}
+	stats.unknown = math.Max(left, 1/float64(stats.totals[0]+1))
+
+	for node := range stats.alphas {
+		stats.alphas[node] = 1
+	}
+	key := make(ngram, order)
+	for n := 2; n <= order; n++ {
+		// left mass of the context and mass of lower order probs of its children
+		left := make(map[uint32]float64)
+		lower := make(map[uint32]float64)
+		for node := 1; node <= size; node++ {
+			if int(depth[node]) != n {
+				continue
+			}
+			parent := nodes.Parents[node-1]
+			if _, ok := left[parent]; !ok {
+				left[parent] = 1
+			}
+			left[parent] -= stats.discounted(trie, uint32(node), parent, n)
+			lower[parent] += stats.katz(trie, nodes.key(uint32(node), key)[1:])
+		}
+		for context := range left {
+			if lower[context] < 1 {
+				stats.alphas[context] = math.Max(left[context], 0) / (1 - lower[context])
+			}
+		}
+	}
+
+	return stats
+}
+
+// katzDiscounts - returns Good-Turing discounts of counts up to katzMaxCount
+// from amounts of n-grams with counts 1 to katzMaxCount+1. Absolute discount
+// of half of the count is used if it can't be estimated
+func katzDiscounts(n [katzMaxCount + 1]float64) []float64 {
+	k := float64(katzMaxCount)
+	a := (k + 1) * n[katzMaxCount] / n[0]
+	discounts := make([]float64, katzMaxCount)
+	for i := range discounts {
+		r := float64(i + 1)
+		d := ((r+1)*n[i+1]/(r*n[i]) - a) / (1 - a)
+		if math.IsNaN(d) || math.IsInf(d, 0) || d <= 0 || d >= 1 {
+			d = (r - 0.5) / r
+		}
+		discounts[i] = d
+	}
+	return discounts
+}
+
+// discounted - returns discounted prob of the node given its parent
+func (o *smoothingStats) discounted(trie *WordTrie, node, parent uint32, order int) float64 {
+	if int(parent) >= len(o.totals) || o.totals[parent] == 0 {
+		return 0.0
+	}
+	count := trie.freq(node)
+	if count <= 0 {
+		return 0.0
+	}
+	d := 1.0
+	if count <= katzMaxCount {
+		d = o.discounts[order-1][count-1]
+	}
+	return d * float64(count) / float64(o.totals[parent])
+}
+
+// katz - returns Katz backoff prob of the last word of key, words without
+// backoff mass left are scored as unknown
+func (o *smoothingStats) katz(trie *WordTrie, key ngram) float64 {
+	if node, parent, ok := trie.find(key); ok {
+		if prob := o.discounted(trie, node, parent, len(key)); prob > 0 {
+			return prob
+		}
+	}
+	if len(key) == 1 {
+		return o.unknown
+	}
+
+	alpha := 1.0
+	if context, _, ok := trie.find(key[:len(key)-1]); ok && int(context) < len(o.alphas) {
+		alpha = o.alphas[context]
+	}
+	if prob := alpha * o.katz(trie, key[1:]); prob > 0 {
+		return prob
+	}
+	return o.unknown
+}
+
+// depths - returns order of each node, 0 is the root
+func (o *trieWire) depths() []uint8 {
+	depth := make([]uint8, len(o.Parents)+1)
+	for i, parent := range o.Parents {
+		depth[i+1] = depth[parent] + 1
+	}
+	return depth
+}
+
+// key - returns words of n-gram of the node, key must fit the longest n-gram
+func (o *trieWire) key(node uint32, key ngram) ngram {
+	n := 0
+	for current := node; current != 0; current = o.Parents[current-1] {
+		n++
+	}
+	key = key[:n]
+	for current := node; current != 0; current = o.Parents[current-1] {
+		n--
+		key[n] = o.Hashes[current-1]
+	}
+	return key
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
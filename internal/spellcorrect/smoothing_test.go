@@ -0,0 +1,116 @@
+package spellcorrect
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func trainSmoothingFrequencies(t *testing.T, smoothing Smoothing) *Frequencies {
+	text := strings.Repeat("i program go\n", 3) + "i code and i cook code\ngo code go\n"
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
+	if err := freq.TrainNgrams(strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	freq.SetSmoothing(smoothing)
+	return freq
+}
+
+func TestSmoothingNormalized(t *testing.T) {
+	vocabulary := []string{"i", "program", "go", "code", "and", "cook", "unknown"}
+	contexts := [][]string{nil, {"i"}, {"code"}, {"i", "program"}, {"and", "i"}, {"cook", "go"}}
+
+	for _, smoothing := range []Smoothing{Katz, KneserNey} {
+		freq := trainSmoothingFrequencies(t, smoothing)
+		for _, context := range contexts {
+			// unknown word stands for all words out of the vocabulary
+			var sum float64
+			for _, word := range vocabulary {
+				tokens := append(append([]string{}, context...), word)
+				sum += math.Exp(freq.LogProb(tokens))
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("%v: probs of words after %q sum to %f", smoothing, context, sum)
+			}
+		}
+	}
+}
+
+func TestSmoothingUnknownWords(t *testing.T) {
+	for _, smoothing := range []Smoothing{StupidBackoff, Katz, KneserNey} {
+		freq := trainSmoothingFrequencies(t, smoothing)
+
+		unknown := freq.LogProb([]string{"i", "program", "rust"})
+		if math.IsInf(unknown, 0) || math.IsNaN(unknown) {
+			t.Errorf("%v: log-prob of unknown word is %f", smoothing, unknown)
+		}
+		if known := freq.LogProb([]string{"i", "program", "go"}); known <= unknown {
+			t.Errorf("%v: known trigram %f is not more probable than unknown word %f", smoothing, known, unknown)
+		}
+		if seen, unseen := freq.LogProb([]string{"i", "code"}), freq.LogProb([]string{"i", "and"}); seen <= unseen {
+			t.Errorf("%v: seen bigram %f is not more probable than unseen %f", smoothing, seen, unseen)
+		}
+	}
+}
+
+func TestStupidBackoff(t *testing.T) {
+	freq := trainSmoothingFrequencies(t, StupidBackoff)
+
+	if prob := math.Exp(freq.LogProb([]string{"i", "program", "go"})); math.Abs(prob-1) > 1e-9 {
+		t.Errorf("wrong prob of trigram %f", prob)
+	}
+	// "i go" is unseen, so prob of "go" is scaled prob of the unigram
+	expected := stupidBackoffFactor * freq.Get([]string{"go"})
+	if prob := math.Exp(freq.LogProb([]string{"i", "go"})); math.Abs(prob-expected) > 1e-9 {
+		t.Errorf("wrong backoff prob %f, expected %f", prob, expected)
+	}
+
+	var buf bytes.Buffer
+	if err := freq.WriteFlat(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	flat, err := ReadFlatFrequencies(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tokens := range [][]string{{"i", "program", "go"}, {"i", "go"}, {"cook", "rust"}, {"rust"}} {
+		if prob, flatProb := freq.LogProb(tokens), flat.LogProb(tokens); math.Abs(prob-flatProb) > 1e-9 {
+			t.Errorf("log-prob of %q in flat model %f, expected %f", tokens, flatProb, prob)
+		}
+	}
+}
+
+func TestSmoothingStatsComputedAhead(t *testing.T) {
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
+	freq.SetSmoothing(Katz)
+	if err := freq.TrainNgrams(strings.NewReader("i program go\ni code go\n")); err != nil {
+		t.Fatal(err)
+	}
+	if freq.stats == nil || len(freq.stats.totals) != freq.Trie.size()+1 {
+		t.Fatalf("Katz statistics are not computed by training")
+	}
+
+	freq.SetSmoothing(KneserNey)
+	if freq.stats == nil {
+		t.Errorf("Kneser-Ney statistics are not computed by setting smoothing")
+	}
+
+	var buf bytes.Buffer
+	if err := freq.EncodeModel(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewFrequencies(0, 0, DefaultNgramOrder)
+	decoded.SetSmoothing(Katz)
+	if err := decoded.DecodeModel(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.stats == nil {
+		t.Errorf("Katz statistics are not computed by loading")
+	}
+
+	freq.Prune(PruneParams{MinCounts: []int{1, 2}})
+	if freq.stats == nil || len(freq.stats.totals) != freq.Trie.size()+1 {
+		t.Errorf("statistics are not computed for pruned trie")
+	}
+}
@@ -0,0 +1,126 @@
+package spellcorrect
+
+import (
+	"math"
+	"sort"
+
+	"github.com/eskriett/spell"
+)
+
+// candidate - correction word, its edit distance and cost which
+// penalizes its score
+type candidate struct {
+	word     string
+	distance int
+	cost     float64
+}
+
+// lookupSpaces - adds candidates of tokens split into two words to
+// allSuggestions and returns candidates of each token merged with the
+// next one, missing or extra space costs one edit
+func (o *SpellCorrector) lookupSpaces(tokens []string, allSuggestions [][]string, dist []map[string]float64, edits []map[string]int) [][]candidate {
+	merges := make([][]candidate, len(tokens))
+	for i := range tokens {
+		for _, split := range o.splits(tokens[i]) {
+			if _, ok := edits[i][split]; ok {
+				continue
+			}
+			allSuggestions[i] = append(allSuggestions[i], split)
+			dist[i][split] = o.cost(tokens[i], split, 1)
+			edits[i][split] = 1
+		}
+		if i+1 < len(tokens) {
+			merges[i] = o.merges(tokens[i], tokens[i+1])
+		}
+	}
+	return merges
+}
+
+// splits - returns up to MaxCandidates splits of the token into two words
+// separated by space ordered by frequency of the words. Both words must
+// be function words or dictionary words not shorter than min word length
+func (o *SpellCorrector) splits(token string) []string {
+	runes := []rune(token)
+	if len(runes) < o.params.MinWordLength {
+		return nil
+	}
+	if _, ok := o.functionWords[token]; ok {
+		return nil
+	}
+
+	type split struct {
+		words string
+		score float64
+	}
+	var splits []split
+	for k := 1; k < len(runes); k++ {
+		left, right := string(runes[:k]), string(runes[k:])
+		leftFreq, ok := o.splitPart(left)
+		if !ok {
+			continue
+		}
+		rightFreq, ok := o.splitPart(right)
+		if !ok {
+			continue
+		}
+		splits = append(splits, split{
+			words: left + " " + right,
+			score: math.Log(float64(leftFreq)) + math.Log(float64(rightFreq)),
+		})
+	}
+
+	sort.SliceStable(splits, func(i, j int) bool {
+		return splits[i].score > splits[j].score
+	})
+	words := make([]string, 0, len(splits))
+	for i := 0; i < len(splits) && i < o.params.MaxCandidates; i++ {
+		words = append(words, splits[i].words)
+	}
+	return words
+}
+
+// splitPart - returns dictionary frequency of the word and whether it
+// can be a part of split token
+func (o *SpellCorrector) splitPart(word string) (uint64, bool) {
+	entry, _ := o.spell.GetEntry(word)
+	if _, ok := o.functionWords[word]; ok {
+		if entry == nil {
+			return 1, true
+		}
+		return entry.Frequency + 1, true
+	}
+	if entry == nil || len([]rune(word)) < o.params.MinWordLength {
+		return 0, false
+	}
+	return entry.Frequency + 1, true
+}
+
+// merges - returns up to MaxCandidates closest dictionary words to the
+// tokens joined together
+func (o *SpellCorrector) merges(left, right string) []candidate {
+	if left == "" || right == "" {
+		return nil
+	}
+
+	suggestions, _ := o.spell.Lookup(left+right, spell.EditDistance(uint32(o.params.EditDistance)), spell.SuggestionLevel(spell.LevelClosest))
+	merges := make([]candidate, 0, len(suggestions))
+	for j := 0; j < len(suggestions) && j < o.params.MaxCandidates; j++ {
+		distance := suggestions[j].Distance + 1
+		merges = append(merges, candidate{
+			word:     suggestions[j].Word,
+			distance: distance,
+			cost:     o.cost(left+" "+right, suggestions[j].Word, float64(distance)),
+		})
+	}
+	return merges
+}
+
+// mergeDistance - returns edit distance of the merge correcting tokens into word
+func mergeDistance(merges []candidate, word string) int {
+	for _, m := range merges {
+		if m.word == word {
+			return m.distance
+		}
+	}
+	return 0
+}
@@ -0,0 +1,43 @@
+package spellcorrect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitsAndMerges(t *testing.T) {
+	sc := trainDecoderCorrector(t)
+	for _, word := range strings.Fields("yellow table cloth for the kitchen red lamp bedroom") {
+		sc.addEntry(word, 10)
+	}
+
+	if splits := sc.splits("tablecloth"); strings.Join(splits, ",") != "table cloth" {
+		t.Errorf("wrong splits %q", splits)
+	}
+	if splits := sc.splits("forthe"); strings.Join(splits, ",") != "for the" {
+		t.Errorf("wrong splits of function words %q", splits)
+	}
+	if merges := sc.merges("kit", "chen"); len(merges) != 1 || merges[0] != (candidate{word: "kitchen", distance: 1, cost: 1}) {
+		t.Errorf("wrong merges %+v", merges)
+	}
+	if merges := sc.merges("kit", "chn"); len(merges) != 1 || merges[0] != (candidate{word: "kitchen", distance: 2, cost: 2}) {
+		t.Errorf("wrong merges with typo %+v", merges)
+	}
+
+	suggestion := sc.suggestTokens(strings.Fields("yellow tablecloth forthe kit chen"), nil, 1, true)[0]
+	if strings.Join(suggestion.Tokens, " ") != "yellow table cloth for the kitchen" {
+		t.Errorf("wrong tokens %q", suggestion.Tokens)
+	}
+	if strings.Join(suggestion.Corrections, ",") != "yellow,table cloth,for the,kitchen," {
+		t.Errorf("wrong corrections %q", suggestion.Corrections)
+	}
+	if len(suggestion.Distances) != 5 || suggestion.Distances[1] != 1 || suggestion.Distances[3] != 1 {
+		t.Errorf("wrong distances %v", suggestion.Distances)
+	}
+
+	// tokens of windows are not split
+	suggestion = sc.suggestTokens(strings.Fields("yellow tablecloth"), nil, 1, false)[0]
+	if strings.Join(suggestion.Tokens, " ") != "yellow tablecloth" {
+		t.Errorf("wrong tokens without spaces %q", suggestion.Tokens)
+	}
+}
@@ -2,30 +2,52 @@ package spellcorrect
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"math"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
-	"unicode"
 
 	"github.com/eskriett/spell"
 	"github.com/segmentio/fasthash/fnv1a"
 )
 
+var errMissingFreq = errors.New("expected \"word freq\"")
+
 type Suggestion struct {
 	score  float64
 	Tokens []string
+	// Corrections - correction of each input token: tokens of split input
+	// token are separated by space, input token merged with the previous
+	// one gets empty correction
+	Corrections []string
+	// Distances - edit distance between each input token and its correction
+	Distances []int
+}
+
+// Score - returns log-likelihood score of the suggestion, higher is better
+func (o Suggestion) Score() float64 {
+	return o.score
 }
 
 // FrequencyContainer - all the necessary functions for working with the frequency layer
 type FrequencyContainer interface {
 	TrainNgrams(in io.Reader) error
 	Get(tokens []string) float64
+	LogProb(tokens []string) float64
 	LoadModel(filename string) error
+	LoadModelFrom(r io.Reader) error
 	SaveModel(filename string) error
+	SaveModelTo(w io.Writer) error
+	EncodeModel(w io.Writer) error
+	DecodeModel(r io.Reader) error
+	TrainParams() (minWord, minFreq int)
+	NgramOrder() int
 	TrainNgramsOnline(tokens []string) error
 }
 
@@ -34,6 +56,44 @@ type Tokenizer interface {
 	Tokens(in io.Reader) ([]string, error)
 }
 
+// LookupParams - parameters of the candidates lookup in spell library
+type LookupParams struct {
+	// MaxEditDistance - max edit distance of the deletes stored in dictionary,
+	// must be set before the dictionary is loaded
+	MaxEditDistance int
+	// EditDistance - max edit distance of the looked up candidates
+	EditDistance int
+	// MaxCandidates - max amount of candidates for each token
+	MaxCandidates int
+	// MinWordLength - shorter tokens are left as is
+	MinWordLength int
+	// MaxSuggestions - amount of best suggestions returned by SpellCorrect
+	MaxSuggestions int
+	// BeamWidth - amount of partial suggestions kept by the decoder, at least
+	// amount of requested suggestions are kept
+	BeamWidth int
+	// ShortWordLength - shorter tokens get function words as candidates
+	ShortWordLength int
+}
+
+// DefaultLookupParams - returns default lookup parameters
+func DefaultLookupParams() LookupParams {
+	return LookupParams{
+		MaxEditDistance: 3,
+		EditDistance:    1,
+		MaxCandidates:   5,
+		MinWordLength:   3,
+		MaxSuggestions:  10,
+		BeamWidth:       10,
+		ShortWordLength: 4,
+	}
+}
+
+// SpellCorrector - corrects typos with n-grams context. SpellCorrect, Suggestions
+// and SpellCorrectWithoutContext are safe for concurrent use, also in auto train
+// mode. SetLookupParams, SetLearnerParams, Train, LoadModel and LoadFreqDict must
+// not be called concurrently with them. In auto train mode corrected queries are
+// learned by single background goroutine, Close stops it
 type SpellCorrector struct {
 	tokenizer     Tokenizer
 	frequencies   FrequencyContainer
@@ -42,6 +102,33 @@ type SpellCorrector struct {
 	minFreq       int
 	penalty       float64
 	autoTrainMode bool
+	params        LookupParams
+	smoothing     Smoothing
+
+	// functionWords - candidates of short words, list keeps their order
+	functionWords    map[string]struct{}
+	functionWordList []string
+	// layouts - conversions of words typed with wrong keyboard layout
+	layouts []Layout
+	// keyboard - weighted edit distance of candidates
+	keyboard *keyboard
+	// channel - error model of noisy channel scoring, nil if it's not trained
+	channel *errorModel
+
+	// dict - words and frequencies added to spell library, saved in the model
+	dict   map[string]uint64
+	corpus string
+	info   ModelInfo
+	// quantizationBits - bits of probs quantization of flat model, 0 disables it
+	quantizationBits int
+	// verifyModel - checksum of flat n-grams model is verified on load
+	verifyModel bool
+
+	// learnMu - guards read-modify-write of spell entries and dict in auto train mode
+	learnMu       sync.Mutex
+	learnerParams LearnerParams
+	learnerOnce   sync.Once
+	learner       *learner
 }
 
 // NewSpellCorrector - creates new SpellCorrector instance
@@ -57,51 +144,126 @@ func NewSpellCorrector(
 		tokenizer:     tokenizer,
 		frequencies:   frequencies,
 		spell:         spell.New(),
+		dict:          make(map[string]uint64),
 		weights:       weights,
 		minFreq:       minFreq,
 		penalty:       penalty,
 		autoTrainMode: autoTrainMode,
+		learnerParams: DefaultLearnerParams(),
 	}
-	ans.spell.MaxEditDistance = 3
+	ans.SetLookupParams(DefaultLookupParams())
+	ans.SetSmoothing(SmoothingNone)
+	ans.SetFunctionWords(DefaultFunctionWords)
+	ans.SetLayouts(DefaultLayouts())
+	ans.SetAdjacentKeyCost(DefaultAdjacentKeyCost)
 	return &ans
 }
 
-// SaveModel - saves trained speller model
-func (o *SpellCorrector) SaveModel(filename string) error {
-	err := o.frequencies.SaveModel(filename)
-	if err != nil {
-		return err
+// SetSmoothing - sets smoothing of n-grams probs used to score suggestions,
+// with SmoothingNone raw probs are combined with weights of n-grams orders
+func (o *SpellCorrector) SetSmoothing(smoothing Smoothing) {
+	o.smoothing = smoothing
+	if s, ok := o.frequencies.(smoother); ok {
+		s.SetSmoothing(smoothing)
 	}
-	return nil
 }
 
-// LoadModel - loades trained speller model from file
-func (o *SpellCorrector) LoadModel(filename string) error {
-	err := o.frequencies.LoadModel(filename)
-	if err != nil {
-		return err
+// SetLookupParams - sets parameters of the candidates lookup
+func (o *SpellCorrector) SetLookupParams(params LookupParams) {
+	o.params = params
+	o.spell.MaxEditDistance = uint32(params.MaxEditDistance)
+}
+
+// SetLearnerParams - sets parameters of auto train mode learner,
+// has no effect after the first SpellCorrect call
+func (o *SpellCorrector) SetLearnerParams(params LearnerParams) {
+	o.learnerParams = params
+}
+
+// getLearner - returns learner starting it on the first call,
+// returns nil if auto train mode is off or corrector is closed
+func (o *SpellCorrector) getLearner() *learner {
+	o.learnerOnce.Do(func() {
+		if o.autoTrainMode {
+			o.learner = newLearner(o.learnerParams, o.addWordToModel)
+		}
+	})
+	return o.learner
+}
+
+// Flush - waits until all queries corrected before the call are learned
+func (o *SpellCorrector) Flush() {
+	if l := o.getLearner(); l != nil {
+		l.flush()
 	}
-	return nil
 }
 
-// loadFreqDict - loads ferequencies dictionary in spell lib
+// Close - stops auto train mode learner after learning queued queries,
+// queries corrected after Close are not learned
+func (o *SpellCorrector) Close() {
+	// learner can't be started after this
+	o.learnerOnce.Do(func() {})
+	if o.learner != nil {
+		o.learner.close()
+	}
+}
+
+// LearnerStats - returns metrics of auto train mode learner
+func (o *SpellCorrector) LearnerStats() LearnerStats {
+	if l := o.getLearner(); l != nil {
+		return l.stats()
+	}
+	return LearnerStats{}
+}
+
+// addEntry - adds word to spell library and dict
+func (o *SpellCorrector) addEntry(word string, freq uint64) {
+	o.spell.AddEntry(spell.Entry{
+		Frequency: freq,
+		Word:      word,
+	})
+	o.dict[word] = freq
+}
+
+// DictParseError - describes malformed line of the frequencies dictionary
+type DictParseError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *DictParseError) Error() string {
+	return fmt.Sprintf("dictionary line %d %q: %v", e.Line, e.Text, e.Err)
+}
+
+func (e *DictParseError) Unwrap() error {
+	return e.Err
+}
+
+// LoadFreqDict - loads ferequencies dictionary in spell lib
 func (o *SpellCorrector) LoadFreqDict(in io.Reader) error {
+	var line int
 	scanner := bufio.NewScanner(in)
 	for scanner.Scan() {
-		parts := strings.Split(scanner.Text(), " ")
+		line++
+		text := scanner.Text()
+		parts := strings.Fields(text)
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) < 2 {
+			return &DictParseError{Line: line, Text: text, Err: errMissingFreq}
+		}
 		freq, err := strconv.ParseUint(parts[1], 10, 64)
 		if err != nil {
-			return err
+			return &DictParseError{Line: line, Text: text, Err: err}
 		}
 
 		if freq < uint64(o.minFreq) {
 			continue
 		}
 
-		o.spell.AddEntry(spell.Entry{
-			Frequency: freq,
-			Word:      parts[0],
-		})
+		o.addEntry(parts[0], freq)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -170,40 +332,99 @@ func combos(in [][]string) []string {
 	return tmpP
 }
 
+// combinationCosts - returns costs of words of combination c of combos,
+// costs of candidates are looked up at their positions, cost of split
+// candidate is given to its first word
+func combinationCosts(in [][]string, dist []map[string]float64, c int) []float64 {
+	indexes := make([]int, len(in))
+	for i := len(in) - 1; i >= 0; i-- {
+		indexes[i] = c % len(in[i])
+		c /= len(in[i])
+	}
+
+	costs := make([]float64, 0, len(in))
+	for i, j := range indexes {
+		candidate := in[i][j]
+		costs = append(costs, dist[i][candidate])
+		for k := strings.Count(candidate, " "); k > 0; k-- {
+			costs = append(costs, 0)
+		}
+	}
+	return costs
+}
+
 // lookupTokens - finds all the suggestions given by the spell library and takes the top 20 of them
-func (o *SpellCorrector) lookupTokens(tokens []string) ([][]string, map[string]float64) {
+// ranked by weighted edit distance and returns costs and edit distances of suggestions for each token.
+// Words are the tokens as typed, with punctuation which may be keys of keyboard layouts
+func (o *SpellCorrector) lookupTokens(tokens, words []string) ([][]string, []map[string]float64, []map[string]int) {
 	allSuggestions := make([][]string, len(tokens))
-	dist := make(map[string]float64)
+	dist := make([]map[string]float64, len(tokens))
+	edits := make([]map[string]int, len(tokens))
 
 	for i := range tokens {
+		dist[i] = make(map[string]float64)
+		edits[i] = make(map[string]int)
+
 		// dont look at short words
-		if len([]rune(tokens[i])) < 3 {
+		if len([]rune(tokens[i])) < o.params.MinWordLength {
 			allSuggestions[i] = append(allSuggestions[i], tokens[i])
-			dist[tokens[i]] = 0
+			dist[i][tokens[i]] = o.cost(tokens[i], tokens[i], 0)
+			edits[i][tokens[i]] = 0
 		}
 
 		// gets suggestions
 		var suggestions spell.SuggestionList
-		o.spell.MaxEditDistance = 1 
-		suggestions, _ = o.spell.Lookup(tokens[i], spell.SuggestionLevel(spell.LevelClosest))
+		editDistance := spell.EditDistance(uint32(o.params.EditDistance))
+		suggestions, _ = o.spell.Lookup(tokens[i], editDistance, spell.SuggestionLevel(spell.LevelClosest))
 		if len(suggestions) < 2 {
-			suggestions, _ = o.spell.Lookup(tokens[i], spell.SuggestionLevel(spell.LevelAll))
+			suggestions, _ = o.spell.Lookup(tokens[i], editDistance, spell.SuggestionLevel(spell.LevelAll))
 		}
-		// if no words == token gets 5 first suggestions
+		// if no words == token gets first MaxCandidates suggestions
 		if len(allSuggestions[i]) == 0 {
-			for j := 0; j < len(suggestions) && j < 5; j++ {
+			weighted := o.weighSuggestions(tokens[i], suggestions)
+			for j := 0; j < len(suggestions) && j < o.params.MaxCandidates; j++ {
 				allSuggestions[i] = append(allSuggestions[i], suggestions[j].Word)
-				dist[suggestions[j].Word] = float64(suggestions[j].Distance) + float64(j)*o.penalty
+				dist[i][suggestions[j].Word] = o.cost(tokens[i], suggestions[j].Word, weighted[j]+float64(j)*o.penalty)
+				edits[i][suggestions[j].Word] = suggestions[j].Distance
 			}
 		}
 		// if no suggestions returns token
 		if len(allSuggestions[i]) == 0 {
 			allSuggestions[i] = append(allSuggestions[i], tokens[i])
-			dist[tokens[i]] = 0
+			dist[i][tokens[i]] = o.cost(tokens[i], tokens[i], 0)
+			edits[i][tokens[i]] = 0
+		}
+
+		// short words are corrected to function words by context, tokens
+		// without letters such as dashes are not words
+		if len([]rune(tokens[i])) < o.params.ShortWordLength && hasLetter(tokens[i]) {
+			words, distances := o.functionWordCandidates(tokens[i])
+			for j, word := range words {
+				if _, ok := edits[i][word]; ok {
+					continue
+				}
+				allSuggestions[i] = append(allSuggestions[i], word)
+				dist[i][word] = o.cost(tokens[i], word, o.keyboard.distance(tokens[i], word))
+				edits[i][word] = distances[j]
+			}
+		}
+
+		// words typed with wrong keyboard layout are as strong as the token
+		var typed string
+		if i < len(words) {
+			typed = words[i]
+		}
+		for _, word := range o.layoutCandidates(tokens[i], typed) {
+			if _, ok := edits[i][word]; ok {
+				continue
+			}
+			allSuggestions[i] = append(allSuggestions[i], word)
+			dist[i][word] = o.cost(word, word, 0)
+			edits[i][word] = 0
 		}
 	}
 
-	return allSuggestions, dist
+	return allSuggestions, dist, edits
 }
 
 // getInsertPosition - returns the position sorted in descending order
@@ -246,8 +467,8 @@ func insertPosition(suggesses []Suggestion, pos int, sugges Suggestion) {
 	suggesses[pos] = sugges
 }
 
-func newSuggestions() []Suggestion {
-	suggestions := make([]Suggestion, 10)
+func newSuggestions(size int) []Suggestion {
+	suggestions := make([]Suggestion, size)
 
 	for i := range suggestions {
 		suggestions[i].score = math.Inf(-1)
@@ -257,18 +478,29 @@ func newSuggestions() []Suggestion {
 }
 
 // getSuggestionCandidates - returns slice of fixed typos with context N-grams
-func (o *SpellCorrector) getSuggestionCandidates(allSuggestions [][]string, dist map[string]float64) []Suggestion {
+func (o *SpellCorrector) getSuggestionCandidates(allSuggestions [][]string, dist []map[string]float64) []Suggestion {
+	return o.rankCandidates(allSuggestions, dist, o.params.MaxSuggestions)
+}
+
+// rankCandidates - returns n best combinations of suggestions, the rest of slice
+// is filled with empty suggestions if there are less than n combinations. Without
+// smoothing score is not a sum over tokens, so all combinations are scored
+func (o *SpellCorrector) rankCandidates(allSuggestions [][]string, dist []map[string]float64, n int) []Suggestion {
+	if o.smoothing != SmoothingNone {
+		return o.decode(allSuggestions, nil, dist, n)
+	}
+
 	// combine suggestions
 	suggestionStrings := combos(allSuggestions)
 	seen := make(map[uint64]struct{}, len(suggestionStrings))
-	suggestions := newSuggestions()
+	suggestions := newSuggestions(n)
 	for i := range suggestionStrings {
 		sugTokens := strings.Split(suggestionStrings[i], " ")
 		h := hashTokens(sugTokens)
 		if _, ok := seen[h]; !ok {
 			seen[h] = struct{}{}
 			sugges := Suggestion{
-				score:  o.score(sugTokens, dist),
+				score:  o.score(sugTokens, combinationCosts(allSuggestions, dist, i)),
 				Tokens: sugTokens,
 			}
 			pos := getInsertPosition(suggestions, sugges)
@@ -276,73 +508,164 @@ func (o *SpellCorrector) getSuggestionCandidates(allSuggestions [][]string, dist
 		}
 	}
 
+	// none of the candidates is known to the model, they are not scored
+	// so they don't outweigh scores of other windows of the query
+	if math.IsInf(suggestions[0].score, -1) {
+		for i := range suggestions {
+			if suggestions[i].Tokens != nil {
+				suggestions[i].score = 0
+			}
+		}
+	}
+
 	return suggestions
 }
 
-func (o *SpellCorrector) addWordToModel(newWords chan string) {
-	for query := range newWords {
-		var tokens []string
+// addWordToModel - trains spell library and n-grams model on the query
+func (o *SpellCorrector) addWordToModel(query string) {
+	var tokens []string
 
-		words := strings.Fields(query)
-		for _, word := range words {
-			if len([]rune(word)) < 2 {
-				continue
-			}
-			word = strings.TrimRightFunc(word, func(r rune) bool {
-				return !unicode.IsLetter(r) && !unicode.IsNumber(r)
-			})
-			word = strings.ToLower(word)
-			tokens = append(tokens, word)
-
-			// update spell library
-			entry, err := o.spell.GetEntry(word)
-			if err != nil || entry == nil {
-				// add new entry
-				o.spell.AddEntry(spell.Entry{
-					Frequency: 1,
-					Word:      word,
-				})
-				continue
-			}
-			o.spell.AddEntry(spell.Entry{
-				Frequency: entry.Frequency + 1,
-				Word:      entry.Word,
-			})
+	words := strings.Fields(query)
+	for _, word := range words {
+		word = Normalize(word)
+		if len([]rune(word)) < 2 {
+			continue
 		}
-		o.frequencies.TrainNgramsOnline(tokens)
+		tokens = append(tokens, word)
+
+		// update spell library
+		o.learnWord(word)
+	}
+	o.frequencies.TrainNgramsOnline(tokens)
+}
+
+// learnWord - increments frequency of the word in spell library
+func (o *SpellCorrector) learnWord(word string) {
+	o.learnMu.Lock()
+	defer o.learnMu.Unlock()
+
+	entry, err := o.spell.GetEntry(word)
+	if err != nil || entry == nil {
+		// add new entry
+		o.addEntry(word, 1)
+		return
 	}
+	o.addEntry(entry.Word, entry.Frequency+1)
 }
 
 // SpellCorrect - returns suggestions
 func (o *SpellCorrector) SpellCorrect(s string) []Suggestion {
-	// new words for model improvments
-	newWords := make(chan string)
-	if o.autoTrainMode {
-		go o.addWordToModel(newWords)
+	items := o.suggestions(s, o.params.MaxSuggestions)
+
+	// sending data to model improvments
+	if l := o.getLearner(); l != nil {
+		l.add(strings.Join(items[0].Tokens, " "))
+		l.add(s)
+	}
+
+	return items
+}
+
+// Suggestions - returns up to n best suggestions, model is not trained on them
+func (o *SpellCorrector) Suggestions(s string, n int) []Suggestion {
+	items := o.suggestions(s, n)
+	for i := range items {
+		if items[i].Tokens == nil {
+			return items[:i]
+		}
+	}
+	return items
+}
+
+// SpellCorrectTokens - returns the best correction of the words decoded
+// jointly, it has one correction for each word. Words are split and merged
+// to fix missing and extra spaces, so amount of tokens may differ. Corrected words are learned in auto train mode as
+// SpellCorrect does
+func (o *SpellCorrector) SpellCorrectTokens(words []string) Suggestion {
+	tokens := make([]string, len(words))
+	for i := range words {
+		tokens[i] = Normalize(words[i])
 	}
+	best := o.suggestTokens(tokens, words, 1, true)[0]
 
+	if l := o.getLearner(); l != nil {
+		l.add(strings.Join(best.Tokens, " "))
+		l.add(strings.Join(words, " "))
+	}
+
+	return best
+}
+
+// SuggestTokens - returns up to n best corrections of the words decoded
+// jointly, each has one correction for each word and words are split and
+// merged as SpellCorrectTokens does
+func (o *SpellCorrector) SuggestTokens(words []string, n int) []Suggestion {
+	tokens := make([]string, len(words))
+	for i := range words {
+		tokens[i] = Normalize(words[i])
+	}
+	items := o.suggestTokens(tokens, words, n, true)
+	for i := range items {
+		if items[i].Tokens == nil {
+			return items[:i]
+		}
+	}
+	return items
+}
+
+// suggestions - returns n best suggestions with edit distances of their
+// tokens, each token of s is corrected by one token
+func (o *SpellCorrector) suggestions(s string, n int) []Suggestion {
 	tokens, _ := o.tokenizer.Tokens(strings.NewReader(s))
-	allSuggestions, dist := o.lookupTokens(tokens)
-	items := o.getSuggestionCandidates(allSuggestions, dist)
+	words := strings.Fields(s)
+	if len(words) != len(tokens) {
+		words = nil
+	}
+	return o.suggestTokens(tokens, words, n, false)
+}
 
-	// sending data to model improvments
-	if o.autoTrainMode {
-		go func() {
-			sugges := strings.Join(items[0].Tokens, " ")
-			newWords <- sugges
-			newWords <- s
-		}()
+// suggestTokens - returns n best suggestions of normalized tokens with
+// edit distances of their tokens. Joint tokens are decoded by lattice and
+// also split and merged. Words are the tokens as typed or nil
+func (o *SpellCorrector) suggestTokens(tokens, words []string, n int, joint bool) []Suggestion {
+	allSuggestions, dist, edits := o.lookupTokens(tokens, words)
+	var (
+		merges [][]candidate
+		items  []Suggestion
+	)
+	if joint {
+		merges = o.lookupSpaces(tokens, allSuggestions, dist, edits)
+		items = o.decode(allSuggestions, merges, dist, n)
+	} else {
+		items = o.rankCandidates(allSuggestions, dist, n)
+	}
+	for i := range items {
+		if items[i].Tokens == nil {
+			break
+		}
+		if items[i].Corrections == nil {
+			items[i].Corrections = items[i].Tokens
+		}
+		corrections := items[i].Corrections
+		items[i].Distances = make([]int, len(corrections))
+		for j, correction := range corrections {
+			if merges != nil && j+1 < len(corrections) && corrections[j+1] == "" && tokens[j+1] != "" {
+				items[i].Distances[j] = mergeDistance(merges[j], correction)
+				continue
+			}
+			items[i].Distances[j] = edits[j][correction]
+		}
 	}
 
 	return items
 }
 
 func (o *SpellCorrector) SpellCorrectWithoutContext(s string) []string {
-	if len([]rune(s)) < 3 {
+	if len([]rune(s)) < o.params.MinWordLength {
 		return []string{s}
 	}
 
-	suggestions, _ := o.spell.Lookup(s, spell.SuggestionLevel(spell.LevelClosest))
+	suggestions, _ := o.spell.Lookup(s, spell.EditDistance(uint32(o.params.EditDistance)), spell.SuggestionLevel(spell.LevelClosest))
 	result := make([]string, len(suggestions))
 
 	for i := range result {
@@ -405,41 +728,56 @@ func (o *SpellCorrector) GetTrigram(tokens []string) float64 {
 	return prob
 }
 
-// calculateBigramScore - returns bigram score of a given words
-func (o *SpellCorrector) calculateBigramScore(ngrams []string, dist map[string]float64) float64 {
-	var (
-		uniLog float64
-		biLog  float64
-		score  float64
-	)
+// weight - returns weight of n-grams of order n, orders
+// without own weight get the weight of the highest one
+func (o *SpellCorrector) weight(n int) float64 {
+	if n > len(o.weights) {
+		return o.weights[len(o.weights)-1]
+	}
+	return o.weights[n-1]
+}
+
+// calculateNgramScore - returns score of n-grams of order n of given words
+// with costs of each word: weighted log-probs of known n-gram and its
+// prefixes, unknown n-gram is scored twice by n-grams of lower order
+func (o *SpellCorrector) calculateNgramScore(ngrams []string, n int, costs []float64) float64 {
+	if n == 1 {
+		return o.calculateUnigramScore(ngrams, costs)
+	}
 
-	bigrams := TokenNgrams(ngrams, 2)
+	var score float64
+	for i := 0; i+n <= len(ngrams); i++ {
+		gram, gramCosts := ngrams[i:i+n:i+n], costs[i:i+n]
+		prob := o.frequencies.Get(gram)
+		if prob == 0 {
+			tmp := o.calculateNgramScore(gram, n-1, gramCosts)
+			score += tmp + tmp
+			continue
+		}
 
-	// penalty := len(bigrams)
-	for i := range bigrams {
-		bigram := o.frequencies.Get(bigrams[i])
-		if bigram != 0 {
-			biLog = math.Log(bigram)
-			biLog -= getPenalty(biLog, dist[bigrams[i][0]]+dist[bigrams[i][1]])
+		var distance float64
+		for _, cost := range gramCosts {
+			distance += cost
+		}
+		logProb := math.Log(prob)
+		score += logProb - getPenalty(logProb, distance)
 
-			unigram := o.GetUnigram(bigrams[i])
-			if unigram != 0 {
-				uniLog = math.Log(unigram) + o.weights[0]
-				uniLog -= getPenalty(uniLog, dist[bigrams[i][0]])
+		for k := n - 1; k >= 1; k-- {
+			distance -= gramCosts[k]
+			prob := o.frequencies.Get(gram[:k])
+			if prob == 0 {
+				continue
 			}
-
-			score += uniLog + biLog
-		} else {
-			tmp := o.calculateUnigramScore(bigrams[i], dist)
-			score += (tmp + tmp)
+			logProb := math.Log(prob) + o.weight(k)
+			score += logProb - getPenalty(logProb, distance)
 		}
 	}
 
 	return score
 }
 
-// calculateUnigramScore - returns unigram score of a given words
-func (o *SpellCorrector) calculateUnigramScore(ngrams []string, dist map[string]float64) float64 {
+// calculateUnigramScore - returns unigram score of a given words with costs of each word
+func (o *SpellCorrector) calculateUnigramScore(ngrams []string, costs []float64) float64 {
 	var (
 		uniLog float64
 		score  float64
@@ -454,7 +792,7 @@ func (o *SpellCorrector) calculateUnigramScore(ngrams []string, dist map[string]
 		if unigram != 0 {
 			penalty--
 			uniLog = math.Log(unigram)
-			uniLog -= getPenalty(uniLog, dist[unigrams[i][0]])
+			uniLog -= getPenalty(uniLog, costs[i])
 		}
 
 		score += uniLog
@@ -467,45 +805,6 @@ func (o *SpellCorrector) calculateUnigramScore(ngrams []string, dist map[string]
 	return score
 }
 
-// calculateTrigramScore -  returns trigrams score of a given words
-func (o *SpellCorrector) calculateTrigramScore(ngrams []string, dist map[string]float64) float64 {
-	var (
-		uniLog float64
-		biLog  float64
-		triLog float64
-		score  float64
-	)
-
-	trigrams := TokenNgrams(ngrams, 3)
-
-	for i := range trigrams {
-		trigram := o.frequencies.Get(trigrams[i])
-		if trigram != 0 {
-			triLog = math.Log(trigram)
-			triLog -= getPenalty(triLog, dist[trigrams[i][0]]+dist[trigrams[i][1]]+dist[trigrams[i][2]])
-
-			bigram := o.GetBigram(trigrams[i])
-			if bigram != 0 {
-				biLog = math.Log(bigram) + o.weights[1]
-				biLog -= getPenalty(biLog, dist[trigrams[i][0]]+dist[trigrams[i][1]])
-			}
-			unigram := o.GetUnigram(trigrams[i])
-			if unigram != 0 {
-				uniLog = math.Log(unigram) + o.weights[0]
-				uniLog -= getPenalty(uniLog, dist[trigrams[i][0]])
-			}
-
-			score += uniLog + biLog + triLog
-		} else {
-			tmp := o.calculateBigramScore(trigrams[i], dist)
-			score += tmp + tmp
-		}
-
-	}
-
-	return score
-}
-
 // applyPenalty - applies penalty to given score
 func (o *SpellCorrector) applyPenalty(score float64, penalty int) float64 {
 	newScore := score
@@ -515,72 +814,43 @@ func (o *SpellCorrector) applyPenalty(score float64, penalty int) float64 {
 	return newScore
 }
 
-// score - scoring each sentence
-func (o *SpellCorrector) score(tokens []string, dist map[string]float64) float64 {
-	// score := 0.0
-	// for i := 1; i < 4; i++ {
-	// 	grams := TokenNgrams(tokens, i)
-	// 	sum1 := 0.
-	// 	for i := range grams {
-	// 		sum1 += o.frequencies.Get(grams[i])
-	// 	}
-	// 	score += o.weights[i-1] * sum1
-	// }
-
-	var score float64
-
-	switch {
-	case len(tokens) == 1:
-		ngrams := TokenNgrams(tokens, 1)
-		for i := range ngrams {
-			score += o.calculateUnigramScore(ngrams[i], dist)
-		}
-	case len(tokens) == 2:
-		ngrams := TokenNgrams(tokens, 2)
-
-		for i := range ngrams {
-			score += o.calculateBigramScore(ngrams[i], dist)
-		}
-	case len(tokens) >= 3:
-		ngrams := TokenNgrams(tokens, 3)
-
-		for i := range ngrams {
-			score += o.calculateTrigramScore(ngrams[i], dist)
-		}
+// score - scoring each sentence, costs are penalties of each token
+func (o *SpellCorrector) score(tokens []string, costs []float64) float64 {
+	order := o.frequencies.NgramOrder()
+	if o.smoothing != SmoothingNone {
+		return o.smoothedScore(tokens, costs, order)
 	}
 
-	// ngrams := TokenNgrams(tokens, 3)
-	// if len(ngrams) == 0 {
-	// 	ngrams = TokenNgrams(tokens, 2)
-	// 	if len(ngrams) == 0 {
-	// 		ngrams = TokenNgrams(tokens, 1)
-	// 	}
-	// }
-
-	// if len(tokens) > 2 {
-	// 	if tokens[0] == "орел" && tokens[1] == "заправляет" && tokens[2] == "крылья" {
-	// 		fmt.Println(score)
-	// 	}
-	// 	if tokens[0] == "орел" && tokens[1] == "расправляет" && tokens[2] == "крылья" {
-	// 		fmt.Println(score)
-	// 	}
-	// }
+	if len(tokens) < order {
+		order = len(tokens)
+	}
+	if order == 0 {
+		return math.Inf(-1)
+	}
 
-	// for i := range ngrams {
-	// 	switch len(ngrams[i]) {
-	// 	case 1:
-	// 		score += o.calculateUnigramScore(ngrams[i], dist)
-	// 	case 2:
-	// 		score += o.calculateBigramScore(ngrams[i], dist)
-	// 	case 3:
-	// 		score += o.calculateTrigramScore(ngrams[i], dist)
-	// 	}
-	// }
-	// if score != 0 {
-	// 	score = math.Exp(score)
-	// }
+	score := o.calculateNgramScore(tokens, order, costs)
 	if score == 0 {
 		score = math.Inf(-1)
 	}
 	return score
 }
+
+// smoothedScore - returns mean smoothed log-prob of the words given up to
+// order-1 previous words with penalties for edit distances, so scores of
+// queries of different length are comparable
+func (o *SpellCorrector) smoothedScore(tokens []string, costs []float64, order int) float64 {
+	if len(tokens) == 0 {
+		return math.Inf(-1)
+	}
+
+	var score float64
+	for i := range tokens {
+		start := i - (order - 1)
+		if start < 0 {
+			start = 0
+		}
+		logProb := o.frequencies.LogProb(tokens[start : i+1])
+		score += logProb - o.candidatePenalty(logProb, costs[i])
+	}
+	return score / float64(len(tokens))
+}
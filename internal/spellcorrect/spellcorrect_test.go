@@ -1,8 +1,11 @@
 package spellcorrect
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/eskriett/spell"
@@ -10,7 +13,7 @@ import (
 
 func getSpellCorrector() *SpellCorrector {
 	tokenizer := NewSimpleTokenizer()
-	freq := NewFrequencies(0, 0)
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
 	sc := NewSpellCorrector(tokenizer, freq, []float64{100, 15, 5}, false, 1, 4)
 	return sc
 }
@@ -110,6 +113,26 @@ func TestSpellCorrect(t *testing.T) {
 	}
 }
 
+func TestLookupTokensCostsByPosition(t *testing.T) {
+	sc := getSpellCorrector()
+	if err := sc.LoadFreqDict(strings.NewReader("привет 10\nмир 10\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same word is a typo correction of the first token and a layout
+	// conversion of the second one
+	_, dist, _ := sc.lookupTokens([]string{"привт", "ghbdtn"}, nil)
+	if dist[0]["привет"] == 0 || dist[1]["привет"] != 0 {
+		t.Errorf("costs of the same word are mixed up %v", dist)
+	}
+
+	allSuggestions := [][]string{{"при", "при вет"}, {"мир"}}
+	dist = []map[string]float64{{"при": 1, "при вет": 2}, {"мир": 3}}
+	if costs := combinationCosts(allSuggestions, dist, 1); fmt.Sprint(costs) != "[2 0 3]" {
+		t.Errorf("wrong costs of split candidate %v", costs)
+	}
+}
+
 func TestGetSuggestionCandidates(t *testing.T) {
 	tokens := []string{"1", "2", "3"}
 
@@ -146,7 +169,7 @@ func TestGetSuggestionCandidates(t *testing.T) {
 	}
 
 	sc := getSpellCorrector()
-	dist := make(map[string]float64)
+	dist := make([]map[string]float64, len(tokens))
 
 	candidates := sc.getSuggestionCandidates(allSuggestions, dist)
 
@@ -173,3 +196,121 @@ func TestGetSuggestionCandidates(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadFreqDictParseError(t *testing.T) {
+	sc := getSpellCorrector()
+	err := sc.LoadFreqDict(strings.NewReader("golang 100\n\ngoland\n"))
+
+	var parseErr *DictParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("expected DictParseError, got %v", err)
+		return
+	}
+	if parseErr.Line != 3 {
+		t.Errorf("wrong line number %d", parseErr.Line)
+		return
+	}
+
+	err = sc.LoadFreqDict(strings.NewReader("golang many"))
+	if !errors.As(err, &parseErr) || parseErr.Line != 1 {
+		t.Errorf("expected DictParseError on line 1, got %v", err)
+	}
+}
+
+func TestSpellCorrectConcurrentAutoTrain(t *testing.T) {
+	trainwords := "golang 100\ngoland 1\npython 50\njava 70"
+	traindata := `golang python C erlang golang java java golang goland`
+
+	freq := NewFrequencies(0, 0, DefaultNgramOrder)
+	sc := NewSpellCorrector(NewSimpleTokenizer(), freq, []float64{100, 15, 5}, true, 1, 4)
+	if err := sc.Train(strings.NewReader(traindata), strings.NewReader(trainwords)); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				sc.SpellCorrect("golamg pyton jawa")
+				sc.Suggestions("goland java", 3)
+				sc.learnWord(fmt.Sprintf("word%d", i))
+				freq.TrainNgramsOnline([]string{"golang", "java", "python"})
+			}
+		}(i)
+	}
+	wg.Wait()
+	sc.Close()
+
+	if entry, _ := sc.spell.GetEntry("word0"); entry == nil || entry.Frequency != 20 {
+		t.Errorf("lost updates of learned word: %+v", entry)
+		return
+	}
+	if stats := sc.LearnerStats(); stats.Learned+stats.Dropped != 8*20*2 {
+		t.Errorf("not all queries are learned or dropped: %+v", stats)
+	}
+}
+
+func TestSaveLoadModelWithDict(t *testing.T) {
+	trainwords := "golang 100\ngoland 1\npython 50"
+	traindata := `golang python C erlang golang java java golang goland`
+
+	sc := getSpellCorrector()
+	if err := sc.Train(strings.NewReader(traindata), strings.NewReader(trainwords)); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	filename := filepath.Join(t.TempDir(), "model.gz")
+	if err := sc.SaveModel(filename); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	loaded := getSpellCorrector()
+	if err := loaded.LoadModel(filename); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if !loaded.HasDict() {
+		t.Errorf("dictionary is not loaded from model")
+		return
+	}
+	if entry, _ := loaded.spell.GetEntry("python"); entry == nil || entry.Frequency != 50 {
+		t.Errorf("wrong dictionary entry %+v", entry)
+		return
+	}
+	if prob := loaded.frequencies.Get([]string{"golang"}); prob != sc.frequencies.Get([]string{"golang"}) {
+		t.Errorf("wrong n-grams model prob %f", prob)
+		return
+	}
+	if suggestions := loaded.Suggestions("pythn", 1); suggestions[0].Tokens[0] != "python" {
+		t.Errorf("wrong suggestion %v", suggestions[0].Tokens)
+		return
+	}
+
+	// model saved without dictionary
+	if err := sc.frequencies.SaveModel(filename); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	legacy := getSpellCorrector()
+	if err := legacy.LoadModel(filename); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if legacy.HasDict() {
+		t.Errorf("model without dictionary has dictionary")
+	}
+}
+
+func TestWeight(t *testing.T) {
+	sc := NewSpellCorrector(NewSimpleTokenizer(), NewFrequencies(0, 0, 5), []float64{1, 5, 4, 3}, false, 1, 4)
+	for n, expected := range map[int]float64{1: 1, 3: 4, 4: 3, 5: 3} {
+		if weight := sc.weight(n); weight != expected {
+			t.Errorf("weight of order %d is %v, expected %v", n, weight, expected)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type SimpleTokenizer struct {
@@ -23,13 +24,72 @@ func (o *SimpleTokenizer) Tokens(in io.Reader) ([]string, error) {
 	scanner.Split(bufio.ScanWords)
 	var ans []string
 	for scanner.Scan() {
-		s := scanner.Text()
-		s = strings.TrimRightFunc(s, func(r rune) bool {
-			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
-		})
-		ans = append(ans, strings.ToLower(s))
+		ans = append(ans, Normalize(scanner.Text()))
 	}
 	err := scanner.Err()
 
 	return ans, err
 }
+
+// Normalize - lowercases word and trims its leading and trailing punctuation
+func Normalize(word string) string {
+	word = strings.TrimFunc(word, func(r rune) bool {
+		return !isWordRune(r)
+	})
+	return strings.ToLower(word)
+}
+
+// isWordRune - reports whether rune is a letter or a number, other runes
+// are trimmed around words
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// Span - position of the token in the text, Start and End exclude leading
+// and trailing punctuation. Span of the token without letters and numbers
+// is empty and starts at the beginning of the word
+type Span struct {
+	Start     int // byte offset of the first byte
+	End       int // byte offset after the last byte
+	RuneStart int // rune offset of the first rune
+	RuneEnd   int // rune offset after the last rune
+}
+
+// TokenSpans - returns positions of the tokens which Tokens returns for the same text
+func TokenSpans(s string) []Span {
+	var (
+		spans    []Span
+		inWord   bool
+		hasRunes bool
+		span     Span
+		runes    int
+	)
+	for i, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			if inWord {
+				spans = append(spans, span)
+				inWord = false
+			}
+		case !inWord:
+			inWord, hasRunes = true, false
+			span = Span{Start: i, End: i, RuneStart: runes, RuneEnd: runes}
+			fallthrough
+		default:
+			if !isWordRune(r) {
+				break
+			}
+			if !hasRunes {
+				hasRunes = true
+				span.Start, span.RuneStart = i, runes
+			}
+			span.End = i + utf8.RuneLen(r)
+			span.RuneEnd = runes + 1
+		}
+		runes++
+	}
+	if inWord {
+		spans = append(spans, span)
+	}
+	return spans
+}
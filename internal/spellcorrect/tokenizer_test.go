@@ -33,3 +33,31 @@ func TestSimpleTokenizerTokens(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenSpans(t *testing.T) {
+	text := "Мама  «пришла», (домой) — поздно!"
+
+	tokens, err := NewSimpleTokenizer().Tokens(strings.NewReader(text))
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	spans := TokenSpans(text)
+	if len(spans) != len(tokens) {
+		t.Errorf("got %d spans for %d tokens", len(spans), len(tokens))
+		return
+	}
+
+	for i := range spans {
+		if got := strings.ToLower(text[spans[i].Start:spans[i].End]); got != tokens[i] {
+			t.Errorf("span %d is %q, expected %q", i, got, tokens[i])
+			return
+		}
+		runes := []rune(text)
+		if got := strings.ToLower(string(runes[spans[i].RuneStart:spans[i].RuneEnd])); got != tokens[i] {
+			t.Errorf("rune span %d is %q, expected %q", i, got, tokens[i])
+			return
+		}
+	}
+}
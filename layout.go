@@ -0,0 +1,59 @@
+package speller
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Saimunyz/speller/internal/config"
+	"github.com/Saimunyz/speller/internal/spellcorrect"
+)
+
+// KeyboardLayout - keys of two keyboard layouts in the same order, words
+// typed with one of them are converted to the other one
+type KeyboardLayout = config.KeyboardLayout
+
+// Keys of QWERTY and ЙЦУКЕН keyboard layouts in the same order
+const (
+	QWERTYKeys = spellcorrect.QWERTYKeys
+	JCUKENKeys = spellcorrect.JCUKENKeys
+)
+
+// tokenSpans - returns positions of the corrected tokens in the query,
+// spans of words typed with wrong keyboard layout include punctuation
+// typed for letters, e.g. "[kt," corrected to "хлеб"
+func (s *Speller) tokenSpans(query string, tokens []string) []spellcorrect.Span {
+	sc := s.corrector()
+	spans := spellcorrect.TokenSpans(query)
+	for i := range spans {
+		if i >= len(tokens) || tokens[i] == "" {
+			continue
+		}
+		word := wordSpan(query, spans[i])
+		if word != spans[i] && sc.LayoutConverts(query[word.Start:word.End], tokens[i]) {
+			spans[i] = word
+		}
+	}
+	return spans
+}
+
+// wordSpan - returns span of the whole word between spaces which has
+// the token at span
+func wordSpan(query string, span spellcorrect.Span) spellcorrect.Span {
+	for span.Start > 0 {
+		r, size := utf8.DecodeLastRuneInString(query[:span.Start])
+		if unicode.IsSpace(r) {
+			break
+		}
+		span.Start -= size
+		span.RuneStart--
+	}
+	for span.End < len(query) {
+		r, size := utf8.DecodeRuneInString(query[span.End:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		span.End += size
+		span.RuneEnd++
+	}
+	return span
+}
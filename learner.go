@@ -0,0 +1,39 @@
+package speller
+
+import "github.com/Saimunyz/speller/internal/spellcorrect"
+
+// LearnerStats - metrics of auto train mode learner: queue depth and size,
+// amount of learned and dropped queries
+type LearnerStats = spellcorrect.LearnerStats
+
+// LearnPolicy - behaviour of auto train mode when learn queue is full
+type LearnPolicy = spellcorrect.LearnPolicy
+
+const (
+	// LearnDrop - queries are dropped when learn queue is full
+	LearnDrop = spellcorrect.LearnDrop
+	// LearnBlock - correction waits for free space in learn queue
+	LearnBlock = spellcorrect.LearnBlock
+)
+
+// Flush - waits until all queries corrected before the call are learned
+// in auto train mode
+func (s *Speller) Flush() {
+	s.corrector().Flush()
+}
+
+// Close - stops background learning of auto train mode after
+// learning queued queries, speller still corrects queries after Close
+func (s *Speller) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.corrector().Close()
+	return nil
+}
+
+// LearnerStats - returns queue depth and counters of auto train mode learner
+func (s *Speller) LearnerStats() LearnerStats {
+	return s.corrector().LearnerStats()
+}
@@ -0,0 +1,364 @@
+package speller
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/Saimunyz/speller/internal/config"
+	"github.com/Saimunyz/speller/internal/spellcorrect"
+)
+
+// Option - configures Speller created with New
+type Option func(*Speller) error
+
+// WithConfigFile - reads parameters from yaml config file over values set
+// by options given before it, parameters missing in the file keep them.
+// Options given after it override values from the file
+func WithConfigFile(path string) Option {
+	return func(s *Speller) error {
+		if err := config.MergeConfigYML(path, s.cfg); err != nil {
+			return &ConfigError{Path: path, Err: err}
+		}
+		s.configPath = path
+		return nil
+	}
+}
+
+// WithSentencesPath - sets path to gzipped text corpus for n-grams training
+func WithSentencesPath(path string) Option {
+	return func(s *Speller) error {
+		s.cfg.SpellerConfig.SentencesPath = path
+		return nil
+	}
+}
+
+// WithDictPath - sets path to gzipped "word freq" dictionary
+func WithDictPath(path string) Option {
+	return func(s *Speller) error {
+		s.cfg.SpellerConfig.DictPath = path
+		return nil
+	}
+}
+
+// WithPairsPath - sets path to gzipped pairs of typed texts and their
+// corrections separated by tab, error model is trained on them by Train.
+// It needs smoothing other than SmoothingNone
+func WithPairsPath(path string) Option {
+	return func(s *Speller) error {
+		s.cfg.SpellerConfig.PairsPath = path
+		return nil
+	}
+}
+
+// WithSentencesReader - sets uncompressed text corpus for n-grams training,
+// it is used instead of sentences path and is consumed by the first Train call
+func WithSentencesReader(r io.Reader) Option {
+	return func(s *Speller) error {
+		s.sentences = r
+		return nil
+	}
+}
+
+// WithDictReader - sets uncompressed "word freq" dictionary, it is used
+// instead of dict path and is consumed by the first Train call or LoadModel
+// of the model without dictionary
+func WithDictReader(r io.Reader) Option {
+	return func(s *Speller) error {
+		s.dict = r
+		return nil
+	}
+}
+
+// WithMinWordFreq - words which are met less often are ignored in training
+func WithMinWordFreq(freq int) Option {
+	return func(s *Speller) error {
+		if freq < 1 {
+			return optionError("min word freq must be positive, got %d", freq)
+		}
+		s.cfg.SpellerConfig.MinWordFreq = freq
+		return nil
+	}
+}
+
+// WithMinWordLength - shorter words are ignored in n-grams training
+func WithMinWordLength(length int) Option {
+	return func(s *Speller) error {
+		if length < 1 {
+			return optionError("min word length must be positive, got %d", length)
+		}
+		s.cfg.SpellerConfig.MinWordLength = length
+		return nil
+	}
+}
+
+// WithPenalty - sets penalty for the rank of the candidate, must be
+// positive as zero penalty in config means the default one
+func WithPenalty(penalty float64) Option {
+	return func(s *Speller) error {
+		if penalty <= 0 {
+			return optionError("penalty must be positive, got %v", penalty)
+		}
+		s.cfg.SpellerConfig.Penalty = penalty
+		return nil
+	}
+}
+
+// WithWeights - sets weights of unigrams, bigrams and trigrams used without
+// smoothing, higher orders get the weight of trigrams
+func WithWeights(unigram, bigram, trigram float64) Option {
+	return func(s *Speller) error {
+		if unigram == 0 || bigram == 0 || trigram == 0 {
+			return optionError("weights must be non zero")
+		}
+		s.cfg.SpellerConfig.UnigramWeight = unigram
+		s.cfg.SpellerConfig.BigramWeight = bigram
+		s.cfg.SpellerConfig.TrigramWeight = trigram
+		return nil
+	}
+}
+
+// WithNgramWeights - sets weights of n-grams of each order starting from
+// unigrams used without smoothing instead of WithWeights, there must be
+// a weight of each order up to n-gram order
+func WithNgramWeights(weights ...float64) Option {
+	return func(s *Speller) error {
+		if len(weights) == 0 {
+			return optionError("n-gram weights must not be empty")
+		}
+		for _, weight := range weights {
+			if weight == 0 {
+				return optionError("n-gram weights must be non zero")
+			}
+		}
+		s.cfg.SpellerConfig.NgramWeights = append([]float64{}, weights...)
+		return nil
+	}
+}
+
+// WithNgramOrder - sets max order of n-grams from 1 to 5, also used
+// as window size unless it is set
+func WithNgramOrder(order int) Option {
+	return func(s *Speller) error {
+		if order < 1 || order > spellcorrect.MaxNgramOrder {
+			return optionError("n-gram order must be from 1 to %d, got %d", spellcorrect.MaxNgramOrder, order)
+		}
+		s.cfg.SpellerConfig.NgramOrder = order
+		return nil
+	}
+}
+
+// WithAutoTrainMode - enables training of the model on corrected queries
+func WithAutoTrainMode(enabled bool) Option {
+	return func(s *Speller) error {
+		s.cfg.SpellerConfig.AutoTrainMode = enabled
+		return nil
+	}
+}
+
+// WithMaxEditDistance - sets max edit distance of the dictionary index
+func WithMaxEditDistance(distance int) Option {
+	return func(s *Speller) error {
+		if distance < 1 {
+			return optionError("max edit distance must be positive, got %d", distance)
+		}
+		s.cfg.SpellerConfig.MaxEditDistance = distance
+		return nil
+	}
+}
+
+// WithLookupEditDistance - sets max edit distance of the looked up candidates
+func WithLookupEditDistance(distance int) Option {
+	return func(s *Speller) error {
+		if distance < 1 {
+			return optionError("lookup edit distance must be positive, got %d", distance)
+		}
+		s.cfg.SpellerConfig.LookupEditDistance = distance
+		return nil
+	}
+}
+
+// WithMaxCandidates - sets max amount of candidates for each word
+func WithMaxCandidates(n int) Option {
+	return func(s *Speller) error {
+		if n < 1 {
+			return optionError("max candidates must be positive, got %d", n)
+		}
+		s.cfg.SpellerConfig.MaxCandidates = n
+		return nil
+	}
+}
+
+// WithMinLookupLength - shorter words are not corrected
+func WithMinLookupLength(length int) Option {
+	return func(s *Speller) error {
+		if length < 1 {
+			return optionError("min lookup length must be positive, got %d", length)
+		}
+		s.cfg.SpellerConfig.MinLookupLength = length
+		return nil
+	}
+}
+
+// WithMaxSuggestions - sets amount of suggestions ranked for each window
+func WithMaxSuggestions(n int) Option {
+	return func(s *Speller) error {
+		if n < 1 {
+			return optionError("max suggestions must be positive, got %d", n)
+		}
+		s.cfg.SpellerConfig.MaxSuggestions = n
+		return nil
+	}
+}
+
+// WithWindowSize - sets amount of words corrected together
+func WithWindowSize(size int) Option {
+	return func(s *Speller) error {
+		if size < 1 {
+			return optionError("window size must be positive, got %d", size)
+		}
+		s.cfg.SpellerConfig.WindowSize = size
+		return nil
+	}
+}
+
+// WithBeamWidth - sets amount of partial suggestions kept while the query
+// is decoded, wider beam finds better suggestions slower
+func WithBeamWidth(width int) Option {
+	return func(s *Speller) error {
+		if width < 1 {
+			return optionError("beam width must be positive, got %d", width)
+		}
+		s.cfg.SpellerConfig.BeamWidth = width
+		return nil
+	}
+}
+
+// WithJointDecoding - enables correction of the whole query at once instead
+// of overlapping windows, every word is corrected once given all other words
+func WithJointDecoding(enabled bool) Option {
+	return func(s *Speller) error {
+		s.cfg.SpellerConfig.JointDecoding = enabled
+		return nil
+	}
+}
+
+// WithFunctionWords - sets prepositions, conjunctions and other short words
+// which are trained in n-grams and corrected by context, Russian and English
+// function words are used by default
+func WithFunctionWords(words ...string) Option {
+	return func(s *Speller) error {
+		if len(words) == 0 {
+			return optionError("function words must not be empty")
+		}
+		s.cfg.SpellerConfig.FunctionWords = words
+		return nil
+	}
+}
+
+// WithAdjacentKeyCost - sets cost of substitution of adjacent keys of keyboard
+// layouts, QWERTY and ЙЦУКЕН by default, in weighted edit distance of
+// candidates, other edits cost 1. Cost must be greater than 0 and not greater than 1, 0.5 by default
+func WithAdjacentKeyCost(cost float64) Option {
+	return func(s *Speller) error {
+		if cost <= 0 || cost > 1 {
+			return optionError("adjacent key cost must be greater than 0 and not greater than 1, got %v", cost)
+		}
+		s.cfg.SpellerConfig.AdjacentKeyCost = cost
+		return nil
+	}
+}
+
+// WithKeyboardLayouts - sets conversions of words typed with wrong keyboard
+// layout, e.g. "ckjy" typed for "слон". Converted dictionary words are strong
+// candidates scored by n-grams model. QWERTY and ЙЦУКЕН are converted by
+// default, no layouts disable conversion
+func WithKeyboardLayouts(layouts ...KeyboardLayout) Option {
+	return func(s *Speller) error {
+		for _, layout := range layouts {
+			if layout.From == "" || utf8.RuneCountInString(layout.From) != utf8.RuneCountInString(layout.To) {
+				return optionError("keyboard layouts must have the same non zero amount of keys, got %q and %q", layout.From, layout.To)
+			}
+		}
+		s.cfg.SpellerConfig.KeyboardLayouts = append([]KeyboardLayout{}, layouts...)
+		return nil
+	}
+}
+
+// WithLearnQueue - sets size of auto train mode learn queue and policy
+// applied when the queue is full
+func WithLearnQueue(size int, policy LearnPolicy) Option {
+	return func(s *Speller) error {
+		if size < 1 {
+			return optionError("learn queue size must be positive, got %d", size)
+		}
+		s.cfg.SpellerConfig.LearnQueueSize = size
+		switch policy {
+		case LearnDrop:
+			s.cfg.SpellerConfig.LearnQueuePolicy = config.LearnDrop
+		case LearnBlock:
+			s.cfg.SpellerConfig.LearnQueuePolicy = config.LearnBlock
+		default:
+			return optionError("unknown learn queue policy %d", policy)
+		}
+		return nil
+	}
+}
+
+// WithCorpusDescription - sets description of the training corpus saved
+// in the model header, sentences path is used by default
+func WithCorpusDescription(description string) Option {
+	return func(s *Speller) error {
+		s.cfg.SpellerConfig.CorpusDescription = description
+		return nil
+	}
+}
+
+// WithQuantization - sets bits of log-probs quantization of n-grams saved
+// by SaveFlatModel: 8, 16 or 0 to store probs without quantization
+func WithQuantization(bits int) Option {
+	return func(s *Speller) error {
+		if bits != 0 && bits != 8 && bits != 16 {
+			return optionError("quantization bits must be 0, 8 or 16, got %d", bits)
+		}
+		s.cfg.SpellerConfig.QuantizationBits = bits
+		return nil
+	}
+}
+
+// WithVerifyModel - enables verification of checksum of flat n-grams model
+// on load, it reads the whole model instead of mapping it instantly
+func WithVerifyModel(enabled bool) Option {
+	return func(s *Speller) error {
+		s.cfg.SpellerConfig.VerifyModel = enabled
+		return nil
+	}
+}
+
+// WithSmoothing - sets smoothing of n-grams probs used to score suggestions,
+// SmoothingNone is the default. Flat models support only SmoothingNone and
+// StupidBackoff
+func WithSmoothing(smoothing Smoothing) Option {
+	return func(s *Speller) error {
+		if _, ok := smoothingNames[smoothing]; !ok {
+			return optionError("unknown smoothing %d", smoothing)
+		}
+		s.cfg.SpellerConfig.Smoothing = smoothingNames[smoothing]
+		return nil
+	}
+}
+
+// WithPairsReader - sets uncompressed pairs of typed texts and their
+// corrections, it is used instead of pairs path and is consumed by the
+// first Train call. It needs smoothing other than SmoothingNone
+func WithPairsReader(r io.Reader) Option {
+	return func(s *Speller) error {
+		s.pairs = r
+		return nil
+	}
+}
+
+// optionError - returns ConfigError for invalid option value
+func optionError(format string, args ...interface{}) error {
+	return &ConfigError{Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,90 @@
+package speller
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/Saimunyz/speller/internal/spellcorrect"
+)
+
+// PruneParams - parameters of n-grams model pruning: min counts of n-grams
+// of each order and relative entropy threshold
+type PruneParams = spellcorrect.PruneParams
+
+// PruneStats - amount of n-grams of each order before and after pruning
+type PruneStats = spellcorrect.PruneStats
+
+var errMissingCorrection = errors.New("expected query and correction separated by tab")
+
+// Evaluation - accuracy of the speller on evaluation set
+type Evaluation struct {
+	Total   int // amount of queries
+	Correct int // amount of queries corrected exactly as expected
+}
+
+// Accuracy - returns share of correctly corrected queries
+func (o Evaluation) Accuracy() float64 {
+	if o.Total == 0 {
+		return 0
+	}
+	return float64(o.Correct) / float64(o.Total)
+}
+
+// Prune - removes rare and uninformative n-grams from a copy of the model
+// and replaces the current model with it as Reload does. Corrections are
+// served by the current model while the copy is pruned, words learned by it
+// in auto train mode meanwhile are lost. Flat n-grams models can't be pruned
+func (s *Speller) Prune(params PruneParams) (PruneStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.corrector()
+	if err := current.CanPrune(); err != nil {
+		return PruneStats{}, err
+	}
+	sc, err := newSpellCorrector(s.cfg)
+	if err != nil {
+		return PruneStats{}, &ConfigError{Path: s.configPath, Err: err}
+	}
+	if err := current.CopyModelTo(sc); err != nil {
+		return PruneStats{}, err
+	}
+
+	stats, err := sc.Prune(params)
+	if err != nil {
+		return stats, err
+	}
+	s.swap(sc)
+	return stats, nil
+}
+
+// Evaluate - corrects queries of evaluation set and compares them with
+// expected corrections. Evaluation set has "query<TAB>expected" lines,
+// comparison ignores case, returns *EvalParseError on malformed lines and
+// errors of r as is
+func (s *Speller) Evaluate(r io.Reader) (Evaluation, error) {
+	var (
+		eval Evaluation
+		line int
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		parts := strings.Split(text, "\t")
+		if len(parts) != 2 {
+			return eval, &EvalParseError{Line: line, Text: text, Err: errMissingCorrection}
+		}
+
+		eval.Total++
+		if strings.EqualFold(s.SpellCorrect(parts[0]), strings.TrimSpace(parts[1])) {
+			eval.Correct++
+		}
+	}
+	return eval, scanner.Err()
+}
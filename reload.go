@@ -0,0 +1,73 @@
+package speller
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// WatchModel - checks model file every interval and reloads the model when
+// the file is replaced: created or renamed over the old one. New model must be
+// written to a temporary file in the same directory and renamed to filename, so
+// the watcher never sees half-written file. Changes of the file in place are
+// ignored and logged since flat model may be memory mapped from it. onReload is
+// called with the result of each reload, errors are logged if it is nil.
+// Returned function stops watching and waits for the running reload to finish
+func (s *Speller) WatchModel(filename string, interval time.Duration, onReload func(error)) (stop func()) {
+	last, _ := os.Stat(filename)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(filename)
+			if err != nil {
+				continue
+			}
+			if !modelReplaced(last, info) {
+				if modelModified(last, info) {
+					log.Printf("model file %s is modified in place, it is reloaded only when replaced", filename)
+					last = info
+				}
+				continue
+			}
+			// file is remembered even if it is broken to not reload it again
+			last = info
+
+			err = s.Reload(filename)
+			switch {
+			case onReload != nil:
+				onReload(err)
+			case err != nil:
+				log.Printf("model reload failed: %v", err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// modelReplaced - reports whether model file was created or replaced
+func modelReplaced(last, info os.FileInfo) bool {
+	return last == nil || !os.SameFile(last, info)
+}
+
+// modelModified - reports whether the same model file was modified
+func modelModified(last, info os.FileInfo) bool {
+	return !last.ModTime().Equal(info.ModTime()) || last.Size() != info.Size()
+}
@@ -0,0 +1,116 @@
+package speller
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Saimunyz/speller/internal/spellcorrect"
+)
+
+func TestReloadWhileCorrecting(t *testing.T) {
+	s := newTestSpeller(t, WithAutoTrainMode(true))
+	defer s.Close()
+
+	filename := filepath.Join(t.TempDir(), "model.gz")
+	if err := s.SaveModel(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if correct := s.SpellCorrect("желтая скатерть"); correct != "желтая скатерть" {
+					t.Errorf("wrong correction %q", correct)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if err := s.Reload(filename); err != nil {
+			t.Error(err)
+		}
+	}
+	wg.Wait()
+
+	if err := s.Reload(filename); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload(filepath.Join(t.TempDir(), "missing.gz")); err == nil {
+		t.Errorf("expected error for missing model")
+	}
+	if correct := s.SpellCorrect("желтая скатнрть"); correct != "желтая скатерть" {
+		t.Errorf("model is not kept after failed reload, got %q", correct)
+	}
+}
+
+func TestWatchModel(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "model.gz")
+
+	trained := newTestSpeller(t)
+	if err := trained.SaveModel(filename + ".tmp"); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(WithDictReader(strings.NewReader("")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := make(chan error, 1)
+	stop := s.WatchModel(filename, 10*time.Millisecond, func(err error) {
+		reloaded <- err
+	})
+	defer stop()
+
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("model is not reloaded")
+	}
+	if correct := s.SpellCorrect("желтая скатнрть"); correct != "желтая скатерть" {
+		t.Errorf("wrong correction %q", correct)
+	}
+
+	// file rewritten in place may be half-written, it is not reloaded
+	if err := os.WriteFile(filename, []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reloaded:
+		t.Errorf("model modified in place is reloaded: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReloadKeepsDictReaderOnError(t *testing.T) {
+	s, err := New(WithDictReader(strings.NewReader("скатерть\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// model without dictionary is completed by the dictionary reader
+	var datasetErr *DatasetError
+	err = s.reload("model", func(sc *spellcorrect.SpellCorrector) error { return nil })
+	if !errors.As(err, &datasetErr) {
+		t.Fatalf("expected DatasetError, got %v", err)
+	}
+	if s.dict == nil {
+		t.Errorf("dictionary reader is lost by failed reload")
+	}
+}
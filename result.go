@@ -0,0 +1,45 @@
+package speller
+
+import "github.com/Saimunyz/speller/internal/spellcorrect"
+
+// ModelInfo - metadata of the model: format version, creation time, corpus
+// description, n-grams order and training parameters
+type ModelInfo = spellcorrect.ModelInfo
+
+// Token - word of the query and its correction, split word is corrected by two
+// words separated by space and word merged with the previous one has empty correction
+type Token struct {
+	Start      int    // byte offset of the word in the query
+	End        int    // byte offset after the word, trailing punctuation excluded
+	RuneStart  int    // rune offset of the word in the query
+	RuneEnd    int    // rune offset after the word
+	Original   string // original text of the word
+	Correction string // correction with capitalization of the original word
+	Changed    bool   // whether correction differs from the lowercased original
+	Distance   int    // edit distance between original and correction
+}
+
+// Result - corrected query
+type Result struct {
+	Query     string  // original query
+	Corrected string  // query with corrected words, same as SpellCorrect returns
+	Tokens    []Token // words of the query in order of appearance
+}
+
+// Changed - returns only the changed words of the query
+func (o Result) Changed() []Token {
+	var changed []Token
+	for _, token := range o.Tokens {
+		if token.Changed {
+			changed = append(changed, token)
+		}
+	}
+	return changed
+}
+
+// Suggestion - alternative correction of the whole query
+type Suggestion struct {
+	Text   string   // query with corrected words
+	Tokens []string // corrected words with capitalization of the original
+	Score  float64  // log-likelihood score, higher is better
+}
@@ -0,0 +1,34 @@
+package speller
+
+import (
+	"strings"
+
+	"github.com/Saimunyz/speller/internal/spellcorrect"
+)
+
+// Segment - splits text without spaces into the most probable words and
+// corrects their typos, e.g. "желтаяскатертьдлястола" gives "желтая скатерть
+// для стола". Words of text with spaces are segmented separately, words are
+// scored by unigram and bigram probs of the model and get capitalization of
+// the original word, the rest of the text stays byte-identical
+func (s *Speller) Segment(text string) string {
+	sc := s.corrector()
+	spans := spellcorrect.TokenSpans(text)
+	cased := make([]string, len(spans))
+	for i, span := range spans {
+		original := text[span.Start:span.End]
+		tokens := sc.Segment(original).Tokens
+		if len(tokens) == 0 {
+			cased[i] = original
+			continue
+		}
+
+		segmented := strings.Join(tokens, " ")
+		if strings.ToLower(original) == segmented {
+			cased[i] = original
+			continue
+		}
+		cased[i] = applyCase(original, segmented)
+	}
+	return project(text, spans, cased)
+}
@@ -0,0 +1,38 @@
+package speller
+
+import (
+	"github.com/Saimunyz/speller/internal/config"
+	"github.com/Saimunyz/speller/internal/spellcorrect"
+)
+
+// Smoothing - smoothing scheme of n-grams probs used to score suggestions
+type Smoothing = spellcorrect.Smoothing
+
+const (
+	// SmoothingNone - raw n-grams probs are combined with weights of orders
+	// as in previous versions
+	SmoothingNone = spellcorrect.SmoothingNone
+	// StupidBackoff - relative frequency of the longest known n-gram scaled
+	// for each shortened context, cheap and works with flat models
+	StupidBackoff = spellcorrect.StupidBackoff
+	// Katz - Good-Turing discounted probs with backoff to lower orders
+	Katz = spellcorrect.Katz
+	// KneserNey - interpolated modified Kneser-Ney smoothing
+	KneserNey = spellcorrect.KneserNey
+)
+
+// smoothingNames - names of smoothing schemes in config
+var smoothingNames = map[Smoothing]string{
+	SmoothingNone: config.SmoothingNone,
+	StupidBackoff: config.SmoothingStupidBackoff,
+	Katz:          config.SmoothingKatz,
+	KneserNey:     config.SmoothingKneserNey,
+}
+
+// smoothings - smoothing schemes by names in config
+var smoothings = map[string]Smoothing{
+	config.SmoothingNone:          SmoothingNone,
+	config.SmoothingStupidBackoff: StupidBackoff,
+	config.SmoothingKatz:          Katz,
+	config.SmoothingKneserNey:     KneserNey,
+}
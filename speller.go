@@ -1,37 +1,127 @@
 package speller
 
 import (
-	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
-	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Saimunyz/speller/internal/config"
 	"github.com/Saimunyz/speller/internal/spellcorrect"
 )
 
+// Speller - spelling corrector, safe for concurrent use. Train, LoadModel and
+// Reload prepare new model aside and atomically replace the current one, so
+// corrections are served during them and in-flight corrections finish on the
+// old model. Close should be called when speller with auto train mode is no
+// longer needed
 type Speller struct {
-	spellcorrector *spellcorrect.SpellCorrector
+	spellcorrector atomic.Value // *spellcorrect.SpellCorrector
 	cfg            *config.Config
+	// configPath - config file read by WithConfigFile, reported in ConfigError
+	configPath string
+
+	// mu - serializes model replacements, guards readers and closed
+	mu        sync.Mutex
+	sentences io.Reader
+	dict      io.Reader
+	pairs     io.Reader
+	closed    bool
 }
 
-// NewSpeller - creates new speller instance
+var errPairsSmoothing = fmt.Errorf("pairs reader needs 'smoothing' other than %q", config.SmoothingNone)
+
+// NewSpeller - creates new speller instance, terminates the program
+// if config can't be read. Use NewSpellerFromConfig to handle the error
 func NewSpeller(configPapth string) *Speller {
-	cfg, err := config.ReadConfigYML(configPapth)
+	s, err := NewSpellerFromConfig(configPapth)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return s
+}
+
+// NewSpellerFromConfig - creates new speller instance from yaml config file,
+// returns *ConfigError if config can't be read or is invalid
+func NewSpellerFromConfig(configPath string) (*Speller, error) {
+	return New(WithConfigFile(configPath))
+}
+
+// New - creates new speller instance configured with given options,
+// unset parameters get default values. Returns *ConfigError if
+// resulting configuration is invalid
+func New(opts ...Option) (*Speller, error) {
+	s := &Speller{
+		cfg: &config.Config{},
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := config.SetDefault(s.cfg)
+	if err != nil {
+		return nil, &ConfigError{Path: s.configPath, Err: err}
+	}
+	// error model scores candidates only with smoothing
+	if s.pairs != nil && s.cfg.SpellerConfig.Smoothing == config.SmoothingNone {
+		return nil, &ConfigError{Path: s.configPath, Err: errPairsSmoothing}
+	}
 
+	sc, err := newSpellCorrector(s.cfg)
+	if err != nil {
+		return nil, &ConfigError{Path: s.configPath, Err: err}
+	}
+	s.spellcorrector.Store(sc)
+
+	return s, nil
+}
+
+// corrector - returns current SpellCorrector
+func (s *Speller) corrector() *spellcorrect.SpellCorrector {
+	return s.spellcorrector.Load().(*spellcorrect.SpellCorrector)
+}
+
+// swap - replaces current SpellCorrector with sc and stops learner of
+// the old one, must be called with mu held
+func (s *Speller) swap(sc *spellcorrect.SpellCorrector) {
+	old := s.corrector()
+	s.spellcorrector.Store(sc)
+	if s.closed {
+		sc.Close()
+	}
+	old.Close()
+}
+
+// newSpellCorrector - creates SpellCorrector with given config, returns
+// error if keyboard layouts are invalid
+func newSpellCorrector(cfg *config.Config) (*spellcorrect.SpellCorrector, error) {
 	tokenizerWords := spellcorrect.NewSimpleTokenizer()
-	freq := spellcorrect.NewFrequencies(cfg.SpellerConfig.MinWordLength, cfg.SpellerConfig.MinWordFreq)
+	freq := spellcorrect.NewFrequencies(
+		cfg.SpellerConfig.MinWordLength,
+		cfg.SpellerConfig.MinWordFreq,
+		cfg.SpellerConfig.NgramOrder,
+	)
 
-	weights := []float64{
-		cfg.SpellerConfig.UnigramWeight,
-		cfg.SpellerConfig.BigramWeight,
-		cfg.SpellerConfig.TrigramWeight,
+	weights := cfg.SpellerConfig.NgramWeights
+	if len(weights) == 0 {
+		weights = []float64{
+			cfg.SpellerConfig.UnigramWeight,
+			cfg.SpellerConfig.BigramWeight,
+			cfg.SpellerConfig.TrigramWeight,
+		}
+		// higher orders get the weight of trigrams
+		for len(weights) < cfg.SpellerConfig.NgramOrder {
+			weights = append(weights, cfg.SpellerConfig.TrigramWeight)
+		}
 	}
 
 	sc := spellcorrect.NewSpellCorrector(
@@ -42,48 +132,118 @@ func NewSpeller(configPapth string) *Speller {
 		cfg.SpellerConfig.MinWordFreq,
 		cfg.SpellerConfig.Penalty,
 	)
-
-	spller := &Speller{
-		spellcorrector: sc,
-		cfg:            cfg,
+	sc.SetLookupParams(spellcorrect.LookupParams{
+		MaxEditDistance: cfg.SpellerConfig.MaxEditDistance,
+		EditDistance:    cfg.SpellerConfig.LookupEditDistance,
+		MaxCandidates:   cfg.SpellerConfig.MaxCandidates,
+		MinWordLength:   cfg.SpellerConfig.MinLookupLength,
+		MaxSuggestions:  cfg.SpellerConfig.MaxSuggestions,
+		BeamWidth:       cfg.SpellerConfig.BeamWidth,
+		ShortWordLength: cfg.SpellerConfig.MinWordLength,
+	})
+
+	policy := spellcorrect.LearnDrop
+	if cfg.SpellerConfig.LearnQueuePolicy == config.LearnBlock {
+		policy = spellcorrect.LearnBlock
 	}
-	return spller
+	sc.SetLearnerParams(spellcorrect.LearnerParams{
+		QueueSize: cfg.SpellerConfig.LearnQueueSize,
+		Policy:    policy,
+	})
+	sc.SetQuantizationBits(cfg.SpellerConfig.QuantizationBits)
+	sc.SetVerifyModel(cfg.SpellerConfig.VerifyModel)
+	sc.SetSmoothing(smoothings[cfg.SpellerConfig.Smoothing])
+	sc.SetAdjacentKeyCost(cfg.SpellerConfig.AdjacentKeyCost)
+	if len(cfg.SpellerConfig.FunctionWords) != 0 {
+		sc.SetFunctionWords(cfg.SpellerConfig.FunctionWords)
+	}
+	if cfg.SpellerConfig.KeyboardLayouts != nil {
+		layouts := make([]spellcorrect.Layout, 0, len(cfg.SpellerConfig.KeyboardLayouts))
+		for _, keys := range cfg.SpellerConfig.KeyboardLayouts {
+			layout, err := spellcorrect.NewLayout(keys.From, keys.To)
+			if err != nil {
+				return nil, fmt.Errorf("keyboard layout %q to %q: %w", keys.From, keys.To, err)
+			}
+			layouts = append(layouts, layout)
+		}
+		sc.SetLayouts(layouts)
+	}
+
+	return sc, nil
 }
 
-// Train - train from zero n-grams model with specified in cfg datasets
-func (s *Speller) Train() {
-	file, err := os.Open(s.cfg.SpellerConfig.SentencesPath)
+// Train - train from zero n-grams model with specified in cfg datasets,
+// error model is trained too if pairs of typos and corrections are set.
+// Returns *DatasetError if datasets can't be read or parsed
+func (s *Speller) Train() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sentences, err := openDataset(s.sentences, s.cfg.SpellerConfig.SentencesPath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer file.Close()
+	defer sentences.Close()
 
-	gz, err := gzip.NewReader(file)
+	dict, err := openDataset(s.dict, s.cfg.SpellerConfig.DictPath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer gz.Close()
+	defer dict.Close()
 
-	file2, err := os.Open(s.cfg.SpellerConfig.DictPath)
-	if err != nil {
-		log.Fatal(err)
+	var pairs *dataset
+	if s.pairs != nil || s.cfg.SpellerConfig.PairsPath != "" {
+		pairs, err = openDataset(s.pairs, s.cfg.SpellerConfig.PairsPath)
+		if err != nil {
+			return err
+		}
+		defer pairs.Close()
 	}
-	defer file2.Close()
 
-	gz2, err := gzip.NewReader(file2)
+	corpus := s.cfg.SpellerConfig.CorpusDescription
+	if corpus == "" {
+		corpus = sentences.path
+	}
+	sc, err := newSpellCorrector(s.cfg)
 	if err != nil {
-		log.Fatal(err)
+		return &ConfigError{Path: s.configPath, Err: err}
 	}
-	defer gz2.Close()
+	sc.SetCorpus(corpus)
 
 	log.Printf("starting training...")
 	t0 := time.Now()
-	s.spellcorrector.Train(gz, gz2)
+	err = sc.Train(sentences, dict)
+	if err != nil {
+		return dictError(err, dict.path)
+	}
+	if pairs != nil {
+		err = sc.TrainErrorModel(pairs)
+		if err != nil {
+			return dictError(err, pairs.path)
+		}
+	}
 	t1 := time.Now()
 	log.Printf("Finished[%s]\n", t1.Sub(t0))
 
+	// readers are consumed only by successful training
+	s.sentences, s.dict, s.pairs = nil, nil, nil
+	s.swap(sc)
+
 	//free memory
 	runtime.GC()
+
+	return nil
+}
+
+// dictError - wraps dictionary or pairs parse error in DatasetError
+func dictError(err error, path string) error {
+	var parseErr *DictParseError
+	var pairErr *PairParseError
+	var datasetErr *DatasetError
+	if (errors.As(err, &parseErr) || errors.As(err, &pairErr)) && !errors.As(err, &datasetErr) {
+		return &DatasetError{Path: path, Err: err}
+	}
+	return err
 }
 
 func (s *Speller) splitByWords(line string, amountOfWords int) []string {
@@ -115,113 +275,242 @@ func (s *Speller) splitByWords(line string, amountOfWords int) []string {
 	return lines
 }
 
-func (o *Speller) joinByWords(lines []string, splitedByWords int) string {
-	if len(lines) < 1 {
-		return ""
-	}
-	words := strings.Fields(lines[0])
-	if len(words) < splitedByWords {
-		return lines[0]
+// SpellCorrect2 - corrects all typos in a given query decoding it at once,
+// so short words such as prepositions are corrected by their context with
+// function words as candidates
+func (s *Speller) SpellCorrect2(query string) string {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return query
 	}
 
-	query := strings.Builder{}
+	tokens := s.corrector().SpellCorrectTokens(words).Corrections
 
-	for _, line := range lines {
-		words = strings.Fields(line)
-		query.Grow(len([]rune(words[0])) + 1)
-		query.WriteString(words[0])
-		query.WriteRune(' ')
-	}
+	// returns the most likely option
+	spans := s.tokenSpans(query, tokens)
+	return project(query, spans, casedTokens(query, spans, tokens))
+}
 
-	for _, word := range words[1:] {
-		query.Grow(len([]rune(word)) + 1)
-		query.WriteString(word)
-		query.WriteRune(' ')
+// SpellCorrect - corrects all typos in a given query
+func (s *Speller) SpellCorrect(query string) string {
+	if len(query) < 1 {
+		return query
 	}
 
-	return strings.TrimSpace(query.String())
+	tokens, _ := s.correctTokens(query)
+	spans := s.tokenSpans(query, tokens)
+
+	// returns the most likely option
+	return project(query, spans, casedTokens(query, spans, tokens))
 }
 
-func (s *Speller) SpellCorrect2(query string) string {
-	if len(query) < 1 {
-		return query
+// correctTokens - corrects query by windows of words or jointly, returns
+// corrections and their edit distances, one for each word of the query.
+// Jointly decoded words may be split into two words separated by space
+// and merged with the previous word, then correction is empty
+func (s *Speller) correctTokens(query string) ([]string, []int) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil, nil
 	}
-	var suggestions []string
-	spltQuery := strings.Fields(query)
-	shortWords := make(map[int]string) // saves index and short words
-	longWords := make([]string, 0, len(spltQuery))
-	for i, word := range spltQuery {
-		if len([]rune(word)) < s.cfg.SpellerConfig.MinWordLength {
-			shortWords[i] = word
-			continue
-		}
-		longWords = append(longWords, word)
+
+	sc := s.corrector()
+	if s.cfg.SpellerConfig.JointDecoding {
+		suggestion := sc.SpellCorrectTokens(words)
+		return suggestion.Corrections, suggestion.Distances
 	}
-	for key, value := range shortWords {
-		shortWords[key] = s.spellcorrector.SpellCorrectWithoutContext(value)[0]
+
+	queries := s.splitByWords(query, s.cfg.SpellerConfig.WindowSize)
+	suggestions := make([]spellcorrect.Suggestion, len(queries))
+	for i, query := range queries {
+		suggestions[i] = sc.SpellCorrect(query)[0]
 	}
 
-	queries := s.splitByWords(strings.Join(longWords, " "), 3)
-	for _, query := range queries {
-		suggestion := s.spellcorrector.SpellCorrect(query)
-		suggestions = append(suggestions, strings.Join(suggestion[0].Tokens, " "))
+	// each word is taken from the first window which starts with it,
+	// the tail of the query is taken from the last window
+	last := suggestions[len(suggestions)-1]
+	tokens := make([]string, 0, len(suggestions)+len(last.Tokens)-1)
+	distances := make([]int, 0, cap(tokens))
+	for _, suggestion := range suggestions[:len(suggestions)-1] {
+		tokens = append(tokens, suggestion.Tokens[0])
+		distances = append(distances, suggestion.Distances[0])
 	}
+	tokens = append(tokens, last.Tokens...)
+	distances = append(distances, last.Distances...)
 
-	joined := s.joinByWords(suggestions, 3)
-	words := strings.Fields(joined)
+	return tokens, distances
+}
 
-	var extInd int
-	var result string
-	for j := range spltQuery {
-		if word, ok := shortWords[j]; ok {
-			result = strings.Join([]string{result, word}, " ")
-			continue
+// Correct - corrects all typos in a given query and returns
+// position, original text and correction of each word
+func (s *Speller) Correct(query string) Result {
+	result := Result{Query: query}
+
+	tokens, distances := s.correctTokens(query)
+	spans := s.tokenSpans(query, tokens)
+	cased := casedTokens(query, spans, tokens)
+	result.Tokens = make([]Token, len(tokens))
+	for i := range tokens {
+		original := query[spans[i].Start:spans[i].End]
+		result.Tokens[i] = Token{
+			Start:      spans[i].Start,
+			End:        spans[i].End,
+			RuneStart:  spans[i].RuneStart,
+			RuneEnd:    spans[i].RuneEnd,
+			Original:   original,
+			Correction: cased[i],
+			Changed:    strings.ToLower(original) != tokens[i],
+			Distance:   distances[i],
 		}
-		result = strings.Join([]string{result, words[extInd]}, " ")
-		extInd++
 	}
+	result.Corrected = project(query, spans, cased)
 
-	// returns the most likely option
-	return strings.TrimSpace(result)
+	return result
 }
 
-//SpellCorrect - corrects all typos in a given query
-func (s *Speller) SpellCorrect(query string) string {
-	if len(query) < 1 {
-		return query
+// Suggest - returns up to n best corrections of the whole query ordered by score,
+// query windows are stitched so that overlapping words of windows agree unless
+// the query is decoded jointly
+func (s *Speller) Suggest(query string, n int) []Suggestion {
+	words := strings.Fields(query)
+	if n < 1 || len(words) == 0 {
+		return nil
 	}
 
-	var suggestions []string
+	var beam []window
+	if s.cfg.SpellerConfig.JointDecoding {
+		for _, suggestion := range s.corrector().SuggestTokens(words, n) {
+			beam = append(beam, window{tokens: suggestion.Corrections, score: suggestion.Score()})
+		}
+	} else {
+		beam = s.stitchQuery(query, n)
+	}
+
+	suggestions := make([]Suggestion, len(beam))
+	for i := range beam {
+		spans := s.tokenSpans(query, beam[i].tokens)
+		cased := casedTokens(query, spans, beam[i].tokens)
+		var tokens []string
+		for _, correction := range cased {
+			tokens = append(tokens, strings.Fields(correction)...)
+		}
+		suggestions[i] = Suggestion{
+			Text:   project(query, spans, cased),
+			Tokens: tokens,
+			Score:  beam[i].score,
+		}
+	}
+	return suggestions
+}
 
-	queries := s.splitByWords(query, 3)
-	for _, query := range queries {
-		suggestion := s.spellcorrector.SpellCorrect(query)
-		suggestions = append(suggestions, strings.Join(suggestion[0].Tokens, " "))
+// stitchQuery - returns up to n best corrections of the query stitched from
+// suggestions of its windows
+func (s *Speller) stitchQuery(query string, n int) []window {
+	// more window suggestions are needed to find consistent continuations
+	perWindow := n
+	if perWindow < s.cfg.SpellerConfig.MaxSuggestions {
+		perWindow = s.cfg.SpellerConfig.MaxSuggestions
 	}
 
-	result := s.joinByWords(suggestions, 3)
+	queries := s.splitByWords(query, s.cfg.SpellerConfig.WindowSize)
+	sc := s.corrector()
+	windows := make([][]spellcorrect.Suggestion, len(queries))
+	for i, query := range queries {
+		windows[i] = sc.Suggestions(query, perWindow)
+	}
 
-	// returns the most likely option
-	return result
+	return stitchWindows(windows, s.cfg.SpellerConfig.WindowSize-1, n)
 }
 
-// SpellCorrectAllSuggestions - returns top 10 corrections typos in a given query
-// func (s *Speller) SpellCorrectAllSuggestions(query string) []string {
-// 	suggestions := s.spellcorrector.SpellCorrect(query)
+// window - partial suggestion of the query built from windows
+type window struct {
+	tokens []string
+	score  float64
+}
 
-// 	topSugges := make([]string, 0, 10)
+// stitchWindows - beam search over windows suggestions, each next window
+// adds one word and must agree with the previous words on overlap. If no
+// suggestion of the window agrees, the best one is used
+func stitchWindows(windows [][]spellcorrect.Suggestion, overlap, n int) []window {
+	var beam []window
+	for _, suggestion := range windows[0] {
+		beam = append(beam, window{tokens: suggestion.Tokens, score: suggestion.Score()})
+	}
 
-// 	for i := 0; i < 10 && i < len(suggestions); i++ {
-// 		topSugges = append(topSugges, strings.Join(suggestions[i].Tokens, " "))
-// 	}
+	for _, suggestions := range windows[1:] {
+		var next []window
+		for _, prev := range beam {
+			for _, suggestion := range suggestions {
+				if overlaps(prev.tokens, suggestion.Tokens, overlap) {
+					next = append(next, prev.extend(suggestion, overlap))
+				}
+			}
+		}
+		if len(next) == 0 {
+			for _, prev := range beam {
+				next = append(next, prev.extend(suggestions[0], overlap))
+			}
+		}
 
-// 	return topSugges
-// }
+		sort.SliceStable(next, func(i, j int) bool {
+			return next[i].score > next[j].score
+		})
+		if len(next) > n {
+			next = next[:n]
+		}
+		beam = next
+	}
+
+	if len(beam) > n {
+		beam = beam[:n]
+	}
+	return beam
+}
+
+// overlaps - checks if last words of prev are the first words of next
+func overlaps(prev, next []string, overlap int) bool {
+	for i := 0; i < overlap; i++ {
+		if prev[len(prev)-overlap+i] != next[i] {
+			return false
+		}
+	}
+	return true
+}
 
-// SaveModel - saves trained speller model
+// extend - returns new partial suggestion with words of the window after overlap
+func (o window) extend(suggestion spellcorrect.Suggestion, overlap int) window {
+	tokens := make([]string, 0, len(o.tokens)+len(suggestion.Tokens)-overlap)
+	tokens = append(tokens, o.tokens...)
+	tokens = append(tokens, suggestion.Tokens[overlap:]...)
+	return window{tokens: tokens, score: o.score + suggestion.Score()}
+}
+
+// SaveModel - saves trained speller model together with spell dictionary
 func (s *Speller) SaveModel(filename string) error {
 	fmt.Println("Model saving...")
-	err := s.spellcorrector.SaveModel(filename)
+	err := s.corrector().SaveModel(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Model saved: %s\n", filename)
+
+	return nil
+}
+
+// SaveModelTo - writes model to w in the same format as SaveModel
+func (s *Speller) SaveModelTo(w io.Writer) error {
+	return s.corrector().SaveModelTo(w)
+}
+
+// SaveFlatModel - saves model with n-grams model in flat binary format,
+// LoadModel memory maps it instead of decoding, so memory is shared between
+// processes. Existing file is replaced by rename, mapped models must never be
+// rewritten in place. Probs are quantized if it is set by WithQuantization.
+// Flat n-grams model is read-only, in auto train mode only spell dictionary
+// is learned
+func (s *Speller) SaveFlatModel(filename string) error {
+	fmt.Println("Model saving...")
+	err := s.corrector().SaveFlatModel(filename)
 	if err != nil {
 		return err
 	}
@@ -230,32 +519,110 @@ func (s *Speller) SaveModel(filename string) error {
 	return nil
 }
 
-// LoadModel - loades trained speller model from file
+// SaveFlatModelTo - writes model to w in the same format as SaveFlatModel
+func (s *Speller) SaveFlatModelTo(w io.Writer) error {
+	return s.corrector().SaveFlatModelTo(w)
+}
+
+// LoadModel - loades trained speller model from file, returns *ModelError
+// if model is missing, truncated, corrupt or has unsupported format version.
+// Model contains spell dictionary, dictionary from config is loaded only for
+// old models saved without it, *DatasetError is returned if it can't be read
 func (s *Speller) LoadModel(filename string) error {
 	t := time.Now()
 	fmt.Println("Model loading...")
-	err := s.spellcorrector.LoadModel(filename)
+	err := s.Reload(filename)
 	if err != nil {
 		return err
 	}
+	fmt.Printf("Model loaded[%v]: %s\n", time.Since(t), filename)
+
+	return nil
+}
+
+// LoadModelFS - loades trained speller model from file of fsys, e.g.
+// embedded with go:embed, returns the same errors as LoadModel
+func (s *Speller) LoadModelFS(fsys fs.FS, name string) error {
+	return s.reload(name, func(sc *spellcorrect.SpellCorrector) error {
+		return sc.LoadModelFS(fsys, name)
+	})
+}
+
+// LoadModelFrom - loades model written by SaveModelTo or SaveModel from r,
+// returns the same errors as LoadModel
+func (s *Speller) LoadModelFrom(r io.Reader) error {
+	return s.reload("", func(sc *spellcorrect.SpellCorrector) error {
+		return sc.LoadModelFrom(r)
+	})
+}
+
+// Reload - loads model from file aside and atomically replaces the current
+// one, returns the same errors as LoadModel and keeps the current model on
+// error. Corrections are served by the current model while new one is loading,
+// words learned by it in auto train mode are lost after replacement
+func (s *Speller) Reload(filename string) error {
+	return s.reload(filename, func(sc *spellcorrect.SpellCorrector) error {
+		return sc.LoadModel(filename)
+	})
+}
+
+// reload - loads model named name into new SpellCorrector with load
+// and replaces the current one with it, name is empty for readers
+func (s *Speller) reload(name string, load func(sc *spellcorrect.SpellCorrector) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	file2, err := os.Open(s.cfg.SpellerConfig.DictPath)
+	sc, err := newSpellCorrector(s.cfg)
 	if err != nil {
-		log.Fatal(err)
+		return &ConfigError{Path: s.configPath, Err: err}
 	}
-	defer file2.Close()
-
-	gz, err := gzip.NewReader(file2)
+	err = load(sc)
 	if err != nil {
-		log.Fatal(err)
+		return &ModelError{Path: name, Err: err}
 	}
-	defer gz.Close()
 
-	err = s.spellcorrector.LoadFreqDict(gz)
-	if err != nil {
-		return err
+	if !sc.HasDict() {
+		dict, err := openDataset(s.dict, s.cfg.SpellerConfig.DictPath)
+		if err != nil {
+			return err
+		}
+		defer dict.Close()
+
+		err = sc.LoadFreqDict(dict)
+		if err != nil {
+			return dictError(err, dict.path)
+		}
+		// reader is consumed only by successful reload
+		s.dict = nil
 	}
-	fmt.Printf("Model loaded[%v]: %s\n", time.Since(t), filename)
+	s.checkModelInfo(sc.ModelInfo(), name)
+	s.swap(sc)
 
 	return nil
 }
+
+// ModelInfo - returns metadata of the loaded or trained model
+func (s *Speller) ModelInfo() ModelInfo {
+	return s.corrector().ModelInfo()
+}
+
+// checkModelInfo - warns if model was trained with parameters
+// different from the running config
+func (s *Speller) checkModelInfo(info ModelInfo, name string) {
+	model := "model"
+	if name != "" {
+		model += " " + name
+	}
+	if info.MinWordLength != s.cfg.SpellerConfig.MinWordLength {
+		log.Printf("warning: %s was trained with min_word_length %d, config has %d",
+			model, info.MinWordLength, s.cfg.SpellerConfig.MinWordLength)
+	}
+	if info.MinWordFreq != s.cfg.SpellerConfig.MinWordFreq {
+		log.Printf("warning: %s was trained with min_word_freq %d, config has %d",
+			model, info.MinWordFreq, s.cfg.SpellerConfig.MinWordFreq)
+	}
+	if info.NgramOrder != s.cfg.SpellerConfig.NgramOrder {
+		log.Printf("warning: %s has n-grams of order %d, config has ngram_order %d",
+			model, info.NgramOrder, s.cfg.SpellerConfig.NgramOrder)
+	}
+}
@@ -0,0 +1,699 @@
+package speller
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Saimunyz/speller/internal/config"
+)
+
+func newTestSpeller(t *testing.T, opts ...Option) *Speller {
+	sentences := strings.Repeat("желтая скатерть для стола\nкрасная скатерть для кухни\n", 5)
+	dict := "желтая 10\nскатерть 20\nдля 50\nстола 10\nкрасная 10\nкухни 10\n"
+
+	opts = append([]Option{
+		WithSentencesReader(strings.NewReader(sentences)),
+		WithDictReader(strings.NewReader(dict)),
+		WithMinWordFreq(1),
+		WithMinWordLength(1),
+	}, opts...)
+
+	s, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Train(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewWithOptions(t *testing.T) {
+	s := newTestSpeller(t, WithWindowSize(2), WithMaxCandidates(3))
+
+	if s.cfg.SpellerConfig.WindowSize != 2 || s.cfg.SpellerConfig.MaxCandidates != 3 {
+		t.Errorf("options are not applied")
+		return
+	}
+	if s.cfg.SpellerConfig.Penalty == 0 || s.cfg.SpellerConfig.MaxSuggestions == 0 {
+		t.Errorf("defaults are not applied")
+		return
+	}
+
+	if correct := s.SpellCorrect("желтая скатнрть"); correct != "желтая скатерть" {
+		t.Errorf("wrong correction %q", correct)
+	}
+}
+
+func TestNewInvalidOption(t *testing.T) {
+	_, err := New(WithWindowSize(0))
+
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Errorf("expected ConfigError, got %v", err)
+	}
+}
+
+func TestTrainWithoutDataset(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var datasetErr *DatasetError
+	if err := s.Train(); !errors.As(err, &datasetErr) {
+		t.Errorf("expected DatasetError, got %v", err)
+	}
+}
+
+func TestConfigFileKeepsOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("speller_config:\n  max_candidates: 7\n  window_size: 4\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(WithMinWordFreq(2), WithWindowSize(2), WithConfigFile(path), WithMaxSuggestions(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := s.cfg.SpellerConfig
+	if cfg.MinWordFreq != 2 || cfg.MaxSuggestions != 3 {
+		t.Errorf("options are lost, min word freq %d and max suggestions %d", cfg.MinWordFreq, cfg.MaxSuggestions)
+	}
+	if cfg.MaxCandidates != 7 || cfg.WindowSize != 4 {
+		t.Errorf("config file is not applied, max candidates %d and window size %d", cfg.MaxCandidates, cfg.WindowSize)
+	}
+
+	for _, penalty := range []float64{0, -1} {
+		var cfgErr *ConfigError
+		if _, err := New(WithConfigFile(path), WithPenalty(penalty)); !errors.As(err, &cfgErr) {
+			t.Errorf("expected ConfigError for penalty %v, got %v", penalty, err)
+		}
+	}
+}
+
+func TestTrainKeepsReadersOnError(t *testing.T) {
+	s, err := New(WithSentencesReader(strings.NewReader("желтая скатерть\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var datasetErr *DatasetError
+	if err := s.Train(); !errors.As(err, &datasetErr) {
+		t.Fatalf("expected DatasetError, got %v", err)
+	}
+	if s.sentences == nil {
+		t.Errorf("sentences reader is reset by failed training")
+	}
+}
+
+func TestCorrect(t *testing.T) {
+	s := newTestSpeller(t)
+
+	query := "Желтая скатнрть для стола!"
+	result := s.Correct(query)
+
+	if result.Corrected != "Желтая скатерть для стола!" {
+		t.Errorf("wrong correction %q", result.Corrected)
+		return
+	}
+	if len(result.Tokens) != 4 {
+		t.Errorf("expected 4 tokens, got %d", len(result.Tokens))
+		return
+	}
+
+	changed := result.Changed()
+	if len(changed) != 1 {
+		t.Errorf("expected 1 changed token, got %d", len(changed))
+		return
+	}
+	token := changed[0]
+	if token.Original != "скатнрть" || token.Correction != "скатерть" || token.Distance != 1 {
+		t.Errorf("wrong changed token %+v", token)
+		return
+	}
+	if query[token.Start:token.End] != token.Original || token.RuneStart != 7 || token.RuneEnd != 15 {
+		t.Errorf("wrong token span %+v", token)
+		return
+	}
+	if last := result.Tokens[3]; last.Original != "стола" || last.Changed {
+		t.Errorf("wrong last token %+v", last)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	s := newTestSpeller(t, WithWindowSize(2))
+
+	suggestions := s.Suggest("красная скатнрть для кухни", 3)
+	if len(suggestions) == 0 || len(suggestions) > 3 {
+		t.Errorf("wrong amount of suggestions %d", len(suggestions))
+		return
+	}
+	if suggestions[0].Text != "красная скатерть для кухни" {
+		t.Errorf("wrong best suggestion %q", suggestions[0].Text)
+		return
+	}
+	for i := range suggestions {
+		if len(suggestions[i].Tokens) != 4 {
+			t.Errorf("suggestion %q has wrong amount of words", suggestions[i].Text)
+			return
+		}
+		if i > 0 && suggestions[i].Score > suggestions[i-1].Score {
+			t.Errorf("suggestions are not sorted")
+			return
+		}
+	}
+
+	if suggestions := s.Suggest("   ", 3); suggestions != nil {
+		t.Errorf("expected no suggestions for empty query")
+	}
+
+	// dashes are not words and are not corrected to function words
+	for _, query := range []string{"желтая — скатерть", "—"} {
+		for _, suggestion := range s.Suggest(query, 3) {
+			if strings.Contains(suggestion.Text, "а—") || strings.Contains(suggestion.Text, "в—") {
+				t.Errorf("dash corrected to function word in %q", suggestion.Text)
+			}
+		}
+	}
+}
+
+func TestSpellCorrectPreservesCase(t *testing.T) {
+	s := newTestSpeller(t, WithWindowSize(2))
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"Желтая скатнрть!", "Желтая скатерть!"},
+		{"ЖЕЛТАЯ  СКАТНРТЬ, для стола", "ЖЕЛТАЯ  СКАТЕРТЬ, для стола"},
+		{"желтая Скатнрть", "желтая Скатерть"},
+		{"  красная скатерть...", "  красная скатерть..."},
+		{"(скатерть)", "(скатерть)"},
+		{"«желтая» скатерть", "«желтая» скатерть"},
+		{"желтая (скатнрть)", "желтая (скатерть)"},
+		{"«Желтая скатнрть»", "«Желтая скатерть»"},
+	}
+
+	for _, test := range tests {
+		if got := s.SpellCorrect(test.query); got != test.expected {
+			t.Errorf("%q corrected to %q, expected %q", test.query, got, test.expected)
+		}
+	}
+
+	for _, query := range []string{"(скатерть)", "«желтая» скатерть"} {
+		if changed := s.Correct(query).Changed(); len(changed) != 0 {
+			t.Errorf("%q has changed words %v", query, changed)
+		}
+	}
+}
+
+func TestApplyCase(t *testing.T) {
+	tests := []struct {
+		original   string
+		correction string
+		expected   string
+	}{
+		{"скатнрть", "скатерть", "скатерть"},
+		{"Скатнрть", "скатерть", "Скатерть"},
+		{"СКАТНРТЬ", "скатерть", "СКАТЕРТЬ"},
+		{"Ж", "я", "Я"},
+		{"сКАТНРТЬ", "скатерть", "скатерть"},
+	}
+
+	for _, test := range tests {
+		if got := applyCase(test.original, test.correction); got != test.expected {
+			t.Errorf("applyCase(%q, %q) = %q, expected %q", test.original, test.correction, got, test.expected)
+		}
+	}
+}
+
+func TestLoadModelWithoutDictPath(t *testing.T) {
+	s := newTestSpeller(t)
+
+	filename := filepath.Join(t.TempDir(), "model.gz")
+	if err := s.SaveModel(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.LoadModel(filename); err != nil {
+		t.Fatal(err)
+	}
+	if correct := loaded.SpellCorrect("желтая скатнрть"); correct != "желтая скатерть" {
+		t.Errorf("wrong correction %q", correct)
+	}
+
+	var modelErr *ModelError
+	if err := loaded.LoadModel(filepath.Join(t.TempDir(), "missing.gz")); !errors.As(err, &modelErr) {
+		t.Errorf("expected ModelError, got %v", err)
+	}
+}
+
+func TestLoadModelFromReaderAndFS(t *testing.T) {
+	s := newTestSpeller(t)
+
+	var buf bytes.Buffer
+	if err := s.SaveModelTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"models/model.gz": {Data: buf.Bytes()}}
+
+	fromReader, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fromReader.LoadModelFrom(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if correct := fromReader.SpellCorrect("желтая скатнрть"); correct != "желтая скатерть" {
+		t.Errorf("wrong correction %q", correct)
+	}
+
+	fromFS, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fromFS.LoadModelFS(fsys, "models/model.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if correct := fromFS.SpellCorrect("желтая скатнрть"); correct != "желтая скатерть" {
+		t.Errorf("wrong correction %q", correct)
+	}
+
+	var modelErr *ModelError
+	if err := fromFS.LoadModelFS(fsys, "missing.gz"); !errors.As(err, &modelErr) || modelErr.Path != "missing.gz" {
+		t.Errorf("expected ModelError, got %v", err)
+	}
+	if err := fromReader.LoadModelFrom(strings.NewReader("garbage")); !errors.Is(err, ErrModelFormat) {
+		t.Errorf("expected ErrModelFormat, got %v", err)
+	}
+}
+
+func TestLoadFlatModel(t *testing.T) {
+	s := newTestSpeller(t)
+
+	filename := filepath.Join(t.TempDir(), "model.flat")
+	if err := s.SaveFlatModel(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, verify := range []bool{false, true} {
+		loaded, err := New(WithVerifyModel(verify))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := loaded.LoadModel(filename); err != nil {
+			t.Fatal(err)
+		}
+		if correct := loaded.SpellCorrect("желтая скатнрть"); correct != "желтая скатерть" {
+			t.Errorf("wrong correction %q", correct)
+		}
+	}
+
+	loaded, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.LoadModel(filename); err != nil {
+		t.Fatal(err)
+	}
+	if version := loaded.ModelInfo().Version; version != 2 {
+		t.Errorf("wrong model version %d", version)
+	}
+}
+
+func TestPruneAndEvaluate(t *testing.T) {
+	s := newTestSpeller(t)
+
+	evalSet := "желтая скатнрть\tжелтая скатерть\nкрасная скатерть для кухни\tкрасная скатерть для кухни\n"
+	before, err := s.Evaluate(strings.NewReader(evalSet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Total != 2 || before.Accuracy() != 1 {
+		t.Errorf("wrong evaluation %+v", before)
+	}
+
+	// pruned copy replaces the model, the current one is intact
+	current := s.corrector()
+	stats, err := s.Prune(PruneParams{MinCounts: []int{1, 1, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total, pruned := stats.Total(); pruned >= total {
+		t.Errorf("nothing is pruned %+v", stats)
+	}
+	if s.corrector() == current {
+		t.Errorf("model is pruned in place")
+	}
+	if current.GetTrigram([]string{"желтая", "скатерть", "для"}) == 0 {
+		t.Errorf("current model is changed by pruning")
+	}
+	if s.corrector().GetTrigram([]string{"желтая", "скатерть", "для"}) != 0 {
+		t.Errorf("rare trigram is not pruned")
+	}
+	after, err := s.Evaluate(strings.NewReader(evalSet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Accuracy() != 1 {
+		t.Errorf("wrong evaluation after pruning %+v", after)
+	}
+
+	var parseErr *EvalParseError
+	if _, err := s.Evaluate(strings.NewReader("желтая\tжелтая\nno tab\n")); !errors.As(err, &parseErr) || parseErr.Line != 2 {
+		t.Errorf("expected EvalParseError of line 2, got %v", err)
+	}
+}
+
+func TestQuantizedFlatModel(t *testing.T) {
+	s := newTestSpeller(t, WithQuantization(8))
+
+	var buf bytes.Buffer
+	if err := s.SaveFlatModelTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.LoadModelFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	evalSet := "желтая скатнрть\tжелтая скатерть\nкрасная скатерть для кухни\tкрасная скатерть для кухни\n"
+	eval, err := loaded.Evaluate(strings.NewReader(evalSet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eval.Accuracy() != 1 {
+		t.Errorf("wrong evaluation of quantized model %+v", eval)
+	}
+
+	if _, err := New(WithQuantization(4)); err == nil {
+		t.Errorf("expected error for 4 bits quantization")
+	}
+}
+
+func TestSmoothing(t *testing.T) {
+	for _, smoothing := range []Smoothing{SmoothingNone, StupidBackoff, Katz, KneserNey} {
+		s := newTestSpeller(t, WithSmoothing(smoothing))
+
+		if correct := s.SpellCorrect("красная скатнрть для кухни"); correct != "красная скатерть для кухни" {
+			t.Errorf("%v: wrong correction %q", smoothing, correct)
+		}
+		if correct := s.SpellCorrect("желтая скатерть"); correct != "желтая скатерть" {
+			t.Errorf("%v: correct query is changed to %q", smoothing, correct)
+		}
+	}
+
+	if _, err := New(WithSmoothing(Smoothing(42))); err == nil {
+		t.Errorf("expected error for unknown smoothing")
+	}
+	if _, err := New(WithSmoothing(Katz), WithQuantization(8)); err == nil {
+		t.Errorf("expected error for Katz smoothing of quantized model")
+	}
+
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smoothing := s.cfg.SpellerConfig.Smoothing; smoothing != "none" {
+		t.Errorf("default smoothing is %q, expected none", smoothing)
+	}
+
+	// flat model has no counts for Kneser-Ney smoothing
+	filename := filepath.Join(t.TempDir(), "model.flat")
+	if err := newTestSpeller(t).SaveFlatModel(filename); err != nil {
+		t.Fatal(err)
+	}
+	kn, err := New(WithSmoothing(KneserNey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kn.LoadModel(filename); !errors.Is(err, ErrFlatSmoothing) {
+		t.Errorf("expected ErrFlatSmoothing, got %v", err)
+	}
+}
+
+func TestNgramOrder(t *testing.T) {
+	s := newTestSpeller(t, WithNgramOrder(4))
+
+	if s.cfg.SpellerConfig.WindowSize != 4 {
+		t.Errorf("window size %d is not set to n-gram order", s.cfg.SpellerConfig.WindowSize)
+	}
+	if order := s.ModelInfo().NgramOrder; order != 4 {
+		t.Errorf("wrong n-gram order of the model %d", order)
+	}
+	if correct := s.SpellCorrect("желтая скатнрть для стола"); correct != "желтая скатерть для стола" {
+		t.Errorf("wrong correction %q", correct)
+	}
+
+	if _, err := New(WithNgramOrder(6)); err == nil {
+		t.Errorf("expected error for n-gram order 6")
+	}
+
+	weighted := newTestSpeller(t, WithNgramOrder(4), WithNgramWeights(1, 5, 4, 3), WithSmoothing(SmoothingNone))
+	if correct := weighted.SpellCorrect("желтая скатнрть для стола"); correct != "желтая скатерть для стола" {
+		t.Errorf("wrong correction with n-gram weights %q", correct)
+	}
+	if _, err := New(WithNgramOrder(4), WithNgramWeights(1, 5, 4)); err == nil {
+		t.Errorf("expected error for missing weight of 4-grams")
+	}
+	if _, err := New(WithNgramWeights(1, 0, 4)); err == nil {
+		t.Errorf("expected error for zero n-gram weight")
+	}
+}
+
+func TestJointDecoding(t *testing.T) {
+	s := newTestSpeller(t, WithJointDecoding(true))
+
+	query := "Желтая скатнрть для стола — красная скатерть для кухнн"
+	result := s.Correct(query)
+	if result.Corrected != "Желтая скатерть для стола — красная скатерть для кухни" {
+		t.Errorf("wrong correction %q", result.Corrected)
+	}
+	if len(result.Tokens) != len(strings.Fields(query)) {
+		t.Errorf("got %d tokens for %d words", len(result.Tokens), len(strings.Fields(query)))
+	}
+
+	suggestions := s.Suggest("желтая скатнрть", 3)
+	if len(suggestions) == 0 || suggestions[0].Text != "желтая скатерть" {
+		t.Fatalf("wrong suggestions %+v", suggestions)
+	}
+	for i := 1; i < len(suggestions); i++ {
+		if suggestions[i].Score > suggestions[i-1].Score {
+			t.Errorf("suggestions are not ordered by score")
+		}
+	}
+
+	// counts are backed off without smoothing
+	unsmoothed := newTestSpeller(t, WithJointDecoding(true), WithSmoothing(SmoothingNone))
+	if correct := unsmoothed.SpellCorrect(query); correct != "Желтая скатерть для стола — красная скатерть для кухни" {
+		t.Errorf("wrong correction without smoothing %q", correct)
+	}
+}
+
+func TestSpellCorrect2ShortWords(t *testing.T) {
+	// query is decoded at once without smoothing as by default
+	s := newTestSpeller(t, WithMinWordLength(4))
+
+	for query, expected := range map[string]string{
+		"Желтая скатерть жля стола":  "Желтая скатерть для стола",
+		"красная скатерть ддя кухни": "красная скатерть для кухни",
+		"скатерть для стола":         "скатерть для стола",
+		// "да" and "до" are as close, context chooses
+		"скатерть дя стола": "скатерть для стола",
+	} {
+		if correct := s.SpellCorrect2(query); correct != expected {
+			t.Errorf("wrong correction of %q: %q, expected %q", query, correct, expected)
+		}
+	}
+
+	// short words without suggestions are left as is
+	if correct := s.SpellCorrect2("ъ ы"); correct != "ъ ы" {
+		t.Errorf("wrong correction %q", correct)
+	}
+
+	// short words are corrected by context with smoothing as without it
+	smoothed := newTestSpeller(t, WithMinWordLength(4), WithSmoothing(StupidBackoff))
+	if correct := smoothed.SpellCorrect2("Желтая скатерть жля стола"); correct != "Желтая скатерть для стола" {
+		t.Errorf("wrong correction with smoothing %q", correct)
+	}
+}
+
+func TestSpaceErrors(t *testing.T) {
+	for _, smoothing := range []Smoothing{SmoothingNone, StupidBackoff} {
+		testSpaceErrors(t, newTestSpeller(t, WithJointDecoding(true), WithSmoothing(smoothing)))
+	}
+
+	// windows have fixed amount of words, so words are split and merged only
+	// by joint decoding
+	windows := newTestSpeller(t)
+	if correct := windows.SpellCorrect("желтая скатерть длястола"); correct != "желтая скатерть длястола" {
+		t.Errorf("wrong correction by windows %q", correct)
+	}
+}
+
+func testSpaceErrors(t *testing.T, s *Speller) {
+	for query, expected := range map[string]string{
+		"Желтая скатерть длястола":   "Желтая скатерть для стола",
+		"желтая ска терть для стола": "желтая скатерть для стола",
+		"КРАСНАЯСКАТЕРТЬ для кухни":  "КРАСНАЯ СКАТЕРТЬ для кухни",
+	} {
+		if correct := s.SpellCorrect(query); correct != expected {
+			t.Errorf("wrong correction of %q: %q, expected %q", query, correct, expected)
+		}
+		if correct := s.SpellCorrect2(query); correct != expected {
+			t.Errorf("wrong SpellCorrect2 correction of %q: %q, expected %q", query, correct, expected)
+		}
+	}
+
+	result := s.Correct("желтая ска терть длястола")
+	if len(result.Tokens) != 4 {
+		t.Fatalf("got %d tokens for 4 words", len(result.Tokens))
+	}
+	if result.Tokens[1].Correction != "скатерть" || result.Tokens[1].Distance != 1 {
+		t.Errorf("wrong merged token %+v", result.Tokens[1])
+	}
+	if result.Tokens[2].Correction != "" || !result.Tokens[2].Changed {
+		t.Errorf("wrong token merged with the previous one %+v", result.Tokens[2])
+	}
+	if result.Tokens[3].Correction != "для стола" || result.Tokens[3].Distance != 1 {
+		t.Errorf("wrong split token %+v", result.Tokens[3])
+	}
+
+	suggestions := s.Suggest("желтая скатерть длястола", 1)
+	if len(suggestions) == 0 || strings.Join(suggestions[0].Tokens, " ") != "желтая скатерть для стола" {
+		t.Errorf("wrong suggestions %+v", suggestions)
+	}
+}
+
+func TestSegment(t *testing.T) {
+	s := newTestSpeller(t)
+
+	for text, expected := range map[string]string{
+		"желтаяскатертьдлястола":           "желтая скатерть для стола",
+		"Краснаяскатертьдлякухни":          "Красная скатерть для кухни",
+		"желтаяскатерьдлястола":            "желтая скатерть для стола",
+		"ЖЕЛТАЯСКАТЕРТЬ, красная скатерть": "ЖЕЛТАЯ СКАТЕРТЬ, красная скатерть",
+		"": "",
+	} {
+		if segmented := s.Segment(text); segmented != expected {
+			t.Errorf("wrong segmentation of %q: %q, expected %q", text, segmented, expected)
+		}
+	}
+}
+
+func TestKeyboardLayouts(t *testing.T) {
+	s := newTestSpeller(t)
+	if correct := s.SpellCorrect2("Желтая Crfnthnm lkz cnjkf"); correct != "Желтая Скатерть для стола" {
+		t.Errorf("wrong correction %q", correct)
+	}
+	smoothed := newTestSpeller(t, WithSmoothing(StupidBackoff))
+	if correct := smoothed.SpellCorrect("Желтая Crfnthnm lkz cnjkf"); correct != "Желтая Скатерть для стола" {
+		t.Errorf("wrong correction by windows %q", correct)
+	}
+
+	disabled := newTestSpeller(t, WithKeyboardLayouts())
+	if correct := disabled.SpellCorrect("желтая crfnthnm"); correct != "желтая crfnthnm" {
+		t.Errorf("wrong correction without layouts %q", correct)
+	}
+
+	if _, err := New(WithKeyboardLayouts(KeyboardLayout{From: QWERTYKeys, To: "йцу"})); err == nil {
+		t.Errorf("expected error for layouts of different length")
+	}
+
+	// layouts are not dropped silently
+	cfg := &config.Config{}
+	if _, err := config.SetDefault(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.SpellerConfig.KeyboardLayouts = []KeyboardLayout{{From: "qw", To: ""}}
+	if _, err := newSpellCorrector(cfg); err == nil {
+		t.Errorf("expected error for invalid layout")
+	}
+
+	// "[" and "," are keys of "х" and "б", punctuation which is not
+	// converted to a word stays punctuation
+	bread := newTestSpeller(t,
+		WithSentencesReader(strings.NewReader(strings.Repeat("свежий хлеб для стола\n", 5))),
+		WithDictReader(strings.NewReader("свежий 10\nхлеб 10\nдля 50\nстола 10\n")),
+		WithSmoothing(StupidBackoff),
+	)
+	for _, joint := range []bool{false, true} {
+		bread.cfg.SpellerConfig.JointDecoding = joint
+		for query, expected := range map[string]string{
+			"свежий [kt, для стола":  "свежий хлеб для стола",
+			"свежий [kt, для cnjkf,": "свежий хлеб для стола,",
+		} {
+			if correct := bread.SpellCorrect(query); correct != expected {
+				t.Errorf("wrong correction of %q: %q, expected %q", query, correct, expected)
+			}
+		}
+	}
+	token := bread.Correct("свежий [kt, для стола").Tokens[1]
+	if token.Original != "[kt," || token.Correction != "хлеб" || token.RuneStart != 7 || token.RuneEnd != 11 {
+		t.Errorf("wrong token %+v", token)
+	}
+}
+
+func TestAdjacentKeyCost(t *testing.T) {
+	s := newTestSpeller(t, WithAdjacentKeyCost(1))
+	if s.cfg.SpellerConfig.AdjacentKeyCost != 1 {
+		t.Errorf("option is not applied")
+	}
+	if correct := s.SpellCorrect("желтая скатерть для стлоа"); correct != "желтая скатерть для стола" {
+		t.Errorf("wrong correction %q", correct)
+	}
+
+	for _, cost := range []float64{0, -1, 1.5} {
+		if _, err := New(WithAdjacentKeyCost(cost)); err == nil {
+			t.Errorf("expected error for adjacent key cost %v", cost)
+		}
+	}
+}
+
+func TestErrorModel(t *testing.T) {
+	pairs := "стлоа\tстола\t10\nжелтя\tжелтая\t5\nскатерть\tскатерть\t100\n"
+	s := newTestSpeller(t, WithPairsReader(strings.NewReader(pairs)), WithSmoothing(StupidBackoff))
+	if info := s.ModelInfo(); info.ErrorPairs != 3 {
+		t.Errorf("model info has %d error pairs, expected 3", info.ErrorPairs)
+	}
+	if correct := s.SpellCorrect("желтя скатерть для стлоа"); correct != "желтая скатерть для стола" {
+		t.Errorf("wrong correction %q", correct)
+	}
+
+	var datasetErr *DatasetError
+	var pairErr *PairParseError
+	s, err := New(
+		WithSentencesReader(strings.NewReader("желтая скатерть\n")),
+		WithDictReader(strings.NewReader("желтая 10\nскатерть 20\n")),
+		WithPairsReader(strings.NewReader("желтя желтая\n")),
+		WithSmoothing(StupidBackoff),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Train(); !errors.As(err, &datasetErr) || !errors.As(err, &pairErr) || pairErr.Line != 1 {
+		t.Errorf("expected DatasetError with pair parse error, got %v", err)
+	}
+
+	// error model is not used without smoothing
+	var configErr *ConfigError
+	if _, err := New(WithPairsReader(strings.NewReader(pairs))); !errors.As(err, &configErr) {
+		t.Errorf("expected ConfigError for pairs reader without smoothing, got %v", err)
+	}
+	if _, err := New(WithPairsPath("pairs.gz"), WithSmoothing(SmoothingNone)); !errors.As(err, &configErr) {
+		t.Errorf("expected ConfigError for pairs path without smoothing, got %v", err)
+	}
+}